@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// addressHashLen is the length of the hash an address pays to.
+const addressHashLen = 20
+
+var errInvalidAddress = errors.New("address is not valid")
+
+// encodeAddress returns the Base58Check address of hash with version.
+func encodeAddress(version byte, hash []byte) string {
+	return Base58CheckEncode(version, hash)
+}
+
+// PayToAddrScript returns the script locking an output to address, an
+// address of the active network. Base58Check addresses pay to a public key
+// hash or to a script hash, Bech32 addresses to a version 0 witness program.
+func PayToAddrScript(address string) ([]byte, error) {
+	if hrp, _, _, err := Bech32Decode(address); err == nil {
+		return witnessAddrScript(hrp, address)
+	}
+
+	version, hash, err := Base58CheckDecode(address)
+	if err != nil || len(hash) != addressHashLen {
+		return nil, errInvalidAddress
+	}
+
+	switch version {
+	case activeNet.AddressVersion:
+		return PayToPubKeyHashScript(hash), nil
+	case activeNet.ScriptHashVersion:
+		return PayToScriptHashScript(hash), nil
+	}
+	return nil, fmt.Errorf("address is not a %s address", activeNet.Name)
+}
+
+// witnessAddrScript returns the witness program the Bech32 address with the
+// human-readable part hrp pays to. Programs of versions other than 0 have no
+// meaning yet, outputs locked to them could be spent by anyone.
+func witnessAddrScript(hrp, address string) ([]byte, error) {
+	if hrp != activeNet.Bech32HRP {
+		return nil, fmt.Errorf("address is not a %s address", activeNet.Name)
+	}
+	version, program, err := decodeWitnessAddress(hrp, address)
+	if err != nil {
+		return nil, errInvalidAddress
+	}
+
+	switch {
+	case version != 0:
+		return nil, fmt.Errorf("witness version %d addresses are not supported", version)
+	case len(program) == addressHashLen:
+		return PayToWitnessPubKeyHashScript(program), nil
+	default:
+		return PayToWitnessScriptHashScript(program), nil
+	}
+}
+
+// ExtractAddress returns the address a locking script pays to, or an empty
+// string for scripts without an address, such as bare multisig.
+func ExtractAddress(script []byte) string {
+	if pubKeyHash := extractPubKeyHash(script); pubKeyHash != nil {
+		return encodeAddress(activeNet.AddressVersion, pubKeyHash)
+	}
+	if scriptHash := extractScriptHash(script); scriptHash != nil {
+		return encodeAddress(activeNet.ScriptHashVersion, scriptHash)
+	}
+	if version, program, ok := extractWitnessProgram(script); ok {
+		if address, err := encodeWitnessAddress(activeNet.Bech32HRP, version, program); err == nil {
+			return address
+		}
+	}
+	return ""
+}
+
+// ScriptHashAddress returns the address of outputs locked by redeemScript.
+func ScriptHashAddress(redeemScript []byte) string {
+	return encodeAddress(activeNet.ScriptHashVersion, HashPubKey(redeemScript))
+}
+
+// WitnessScriptHashAddress returns the Bech32 address of outputs locked by
+// witnessScript.
+func WitnessScriptHashAddress(witnessScript []byte) string {
+	address, err := encodeWitnessAddress(activeNet.Bech32HRP, 0, WitnessScriptHash(witnessScript))
+	if err != nil {
+		log.Panic(err)
+	}
+	return address
+}
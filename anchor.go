@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"log"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// anchorsBucket indexes the data carried by data outputs.
+const anchorsBucket = "anchors"
+
+var errAnchorNotFound = errors.New("data is not anchored")
+
+// Anchor locates the earliest transaction carrying some data, which proves
+// that the data existed when its block was mined.
+type Anchor struct {
+	Data      []byte
+	TxID      []byte
+	BlockHash []byte
+	Height    int
+	Timestamp int64
+}
+
+// blockAnchors returns the anchors of the data outputs of block.
+func blockAnchors(block *Block) []Anchor {
+	var anchors []Anchor
+
+	for _, tx := range block.Transactions {
+		for _, out := range tx.Vout {
+			data, ok := extractNullData(out.ScriptPubKey)
+			if !ok || len(data) == 0 {
+				continue
+			}
+			anchors = append(anchors, Anchor{
+				Data:      data,
+				TxID:      tx.ID,
+				BlockHash: block.Hash,
+				Height:    block.Height,
+				Timestamp: block.Timestamp,
+			})
+		}
+	}
+
+	return anchors
+}
+
+// putAnchor adds anchor to the index unless the data was anchored earlier.
+func putAnchor(b *bolt.Bucket, anchor Anchor) {
+	if existing := b.Get(anchor.Data); existing != nil {
+		if deserializeAnchor(existing).Height <= anchor.Height {
+			return
+		}
+	}
+
+	err := b.Put(anchor.Data, anchor.Serialize())
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// Serialize returns a serialized Anchor.
+func (a Anchor) Serialize() []byte {
+	var buff bytes.Buffer
+
+	enc := gob.NewEncoder(&buff)
+	err := enc.Encode(a)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+func deserializeAnchor(data []byte) Anchor {
+	var anchor Anchor
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&anchor)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return anchor
+}
+
+// FindAnchor looks up the transaction that first anchored data.
+func (u UTXOSet) FindAnchor(data []byte) (Anchor, error) {
+	var anchor Anchor
+	db := u.Blockchain.db
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(anchorsBucket))
+		if b == nil {
+			return errAnchorNotFound
+		}
+
+		encoded := b.Get(data)
+		if encoded == nil {
+			return errAnchorNotFound
+		}
+		anchor = deserializeAnchor(encoded)
+
+		return nil
+	})
+
+	return anchor, err
+}
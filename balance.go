@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/hex"
+	"log"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Balance is the amount held by an address, split by the state of its
+// unspent outputs.
+type Balance struct {
+	// Confirmed is the value of outputs with enough confirmations.
+	Confirmed int
+	// Unconfirmed is the value of outputs of unconfirmed transactions, and
+	// of outputs with fewer confirmations than asked for.
+	Unconfirmed int
+	// Immature is the value of coinbase outputs with fewer than
+	// CoinbaseMaturity confirmations.
+	Immature int
+}
+
+// GetBalance returns the balance of outputs locked by any of scriptPubKeys.
+// Outputs with fewer than minConf confirmations count as unconfirmed. Outputs spent
+// by unconfirmed transactions are left out, the change they pay back counts
+// as unconfirmed.
+func (u UTXOSet) GetBalance(minConf int, scriptPubKeys ...[]byte) Balance {
+	var balance Balance
+	lockScripts := make(map[string]bool)
+	for _, scriptPubKey := range scriptPubKeys {
+		lockScripts[string(scriptPubKey)] = true
+	}
+
+	bestHeight := u.Blockchain.GetBestHeight()
+	pending := u.findValidPending()
+
+	spent := make(map[string]bool)
+	for _, tx := range pending {
+		for _, vin := range tx.Vin {
+			spent[OutPoint{vin.Txid, vin.Vout}.String()] = true
+		}
+	}
+
+	err := u.Blockchain.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			outs := DeserializeOutputs(v)
+			confirmations := bestHeight - outs.Height + 1
+
+			for i, out := range outs.Outputs {
+				if !lockScripts[string(out.ScriptPubKey)] || spent[OutPoint{k, outs.Index(i)}.String()] {
+					continue
+				}
+
+				switch {
+				case outs.Coinbase && confirmations < activeNet.CoinbaseMaturity:
+					balance.Immature += out.Value
+				case confirmations < minConf:
+					balance.Unconfirmed += out.Value
+				default:
+					balance.Confirmed += out.Value
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for _, tx := range pending {
+		for i, out := range tx.Vout {
+			if lockScripts[string(out.ScriptPubKey)] && !spent[OutPoint{tx.ID, i}.String()] {
+				balance.Unconfirmed += out.Value
+			}
+		}
+	}
+
+	return balance
+}
+
+// findValidPending returns the unconfirmed transactions spending, with valid
+// signatures, unspent outputs or outputs of other valid unconfirmed
+// transactions. The others conflict with the blockchain or cannot be mined.
+func (u UTXOSet) findValidPending() []Transaction {
+	pending := u.Blockchain.FindPending()
+
+	// A transaction spending unconfirmed outputs is valid once the
+	// transactions paying them are.
+	valid := make(map[string]Transaction)
+	for added := true; added; {
+		added = false
+		for i := range pending {
+			txID := hex.EncodeToString(pending[i].ID)
+			if _, ok := valid[txID]; !ok && u.verifyPending(&pending[i], valid) {
+				valid[txID] = pending[i]
+				added = true
+			}
+		}
+	}
+
+	var txs []Transaction
+	for _, tx := range pending {
+		if _, ok := valid[hex.EncodeToString(tx.ID)]; ok {
+			txs = append(txs, tx)
+		}
+	}
+
+	return txs
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBalance(t *testing.T) {
+	params := RegTestParams
+	params.CoinbaseMaturity = 2
+	activeNet = &params
+	defer func() { activeNet = &MainNetParams }()
+
+	alice, bob, miner := NewWallet(), NewWallet(), NewWallet()
+	aliceScript := PayToPubKeyHashScript(HashPubKey(alice.PublicKey))
+	bobScript := PayToPubKeyHashScript(HashPubKey(bob.PublicKey))
+	minerScript := PayToPubKeyHashScript(HashPubKey(miner.PublicKey))
+	bc := testBlockchain(t, string(alice.GetAddress()))
+	UTXOSet := UTXOSet{bc}
+
+	assert.Equal(t, Balance{Immature: 10}, UTXOSet.GetBalance(1, aliceScript))
+
+	// Alice pays Bob out of the genesis reward.
+	payment := NewSendManyTransaction(alice, []Payment{{string(bob.GetAddress()), 4}}, 0, &CoinControl{}, &UTXOSet)
+	UTXOSet.Update(bc.MineBlock([]*Transaction{NewCoinbaseTX(string(miner.GetAddress()), "Block 1"), payment}))
+
+	assert.Equal(t, Balance{Confirmed: 6}, UTXOSet.GetBalance(1, aliceScript))
+	assert.Equal(t, Balance{Unconfirmed: 6}, UTXOSet.GetBalance(2, aliceScript))
+	assert.Equal(t, Balance{Immature: 10}, UTXOSet.GetBalance(1, minerScript))
+
+	refund := NewSendManyTransaction(bob, []Payment{{string(alice.GetAddress()), 1}}, 0, &CoinControl{}, &UTXOSet)
+	bc.AddPending(refund)
+	assert.Equal(t, Balance{Confirmed: 6, Unconfirmed: 1}, UTXOSet.GetBalance(1, aliceScript))
+	assert.Equal(t, Balance{Unconfirmed: 3}, UTXOSet.GetBalance(1, bobScript), "The change of the refund is unconfirmed.")
+
+	// Pending transactions with invalid signatures are ignored.
+	forged := *refund
+	forged.Vout = []TXOutput{*NewTXOutput(4, string(alice.GetAddress()))}
+	forged.ID = forged.ComputeID()
+	bc.AddPending(&forged)
+	assert.Equal(t, Balance{Confirmed: 6, Unconfirmed: 1}, UTXOSet.GetBalance(1, aliceScript))
+
+	// Reindexing records the same heights.
+	UTXOSet.Reindex()
+	assert.Equal(t, Balance{Confirmed: 6, Unconfirmed: 1}, UTXOSet.GetBalance(1, aliceScript))
+	assert.Equal(t, Balance{Immature: 10}, UTXOSet.GetBalance(1, minerScript))
+
+	// A pending transaction conflicting with the blockchain is ignored.
+	UTXOSet.Update(bc.MineBlock([]*Transaction{NewCoinbaseTX(string(miner.GetAddress()), "Block 2"), refund}))
+	bc.AddPending(refund)
+	assert.Equal(t, Balance{Confirmed: 3}, UTXOSet.GetBalance(1, bobScript))
+	assert.Equal(t, Balance{Confirmed: 10, Immature: 10}, UTXOSet.GetBalance(1, minerScript))
+
+	// Outputs paying to the Bech32 address of a key count with the others.
+	toWitness := NewSendManyTransaction(bob, []Payment{{string(alice.GetBech32Address()), 2}}, 0, &CoinControl{}, &UTXOSet)
+	UTXOSet.Update(bc.MineBlock([]*Transaction{NewCoinbaseTX(string(miner.GetAddress()), "Block 3"), toWitness}))
+	assert.Equal(t, Balance{Confirmed: 7}, UTXOSet.GetBalance(1, aliceScript))
+	assert.Equal(t, Balance{Confirmed: 9}, UTXOSet.GetBalance(1, alice.LockScripts()...))
+}
@@ -2,57 +2,124 @@ package main
 
 import (
 	"bytes"
-	"math/big"
+	"errors"
+	"fmt"
 )
 
 var b58Alphabet = []byte("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz")
 
-// Base58Encode encodes a byte array to Base58.
-func Base58Encode(input []byte) []byte {
-	var result []byte
-
-	x := big.NewInt(0).SetBytes(input)
+// b58Indexes maps characters to their value in b58Alphabet, -1 for
+// characters outside of it.
+var b58Indexes = func() [256]int {
+	var indexes [256]int
+	for i := range indexes {
+		indexes[i] = -1
+	}
+	for i, c := range b58Alphabet {
+		indexes[c] = i
+	}
+	return indexes
+}()
 
-	base := big.NewInt(int64(len(b58Alphabet)))
-	zero := big.NewInt(0)
-	mod := &big.Int{}
+var (
+	errBase58Checksum = errors.New("base58: checksum mismatch")
+	errBase58Length   = errors.New("base58: too short for a version and a checksum")
+)
 
-	for x.Cmp(zero) != 0 {
-		x.DivMod(x, base, mod)
-		result = append(result, b58Alphabet[mod.Int64()])
+// Base58Encode encodes a byte array to Base58. Each leading zero byte is
+// encoded as a leading 1.
+func Base58Encode(input []byte) []byte {
+	zeros := 0
+	for zeros < len(input) && input[zeros] == 0 {
+		zeros++
 	}
 
-	ReverseBytes(result)
-	for b := range input {
-		if b == 0x00 {
-			result = append([]byte{b58Alphabet[0]}, result...)
-		} else {
-			break
+	// digits holds the Base58 digits of the rest of input, most significant
+	// last. log(256)/log(58) < 1.37 digits are needed per byte.
+	digits := make([]byte, 0, (len(input)-zeros)*137/100+1)
+	for _, b := range input[zeros:] {
+		carry := int(b)
+		for i := range digits {
+			carry += int(digits[i]) << 8
+			digits[i] = byte(carry % 58)
+			carry /= 58
 		}
+		for carry > 0 {
+			digits = append(digits, byte(carry%58))
+			carry /= 58
+		}
+	}
+
+	result := make([]byte, zeros+len(digits))
+	for i := 0; i < zeros; i++ {
+		result[i] = b58Alphabet[0]
+	}
+	for i, digit := range digits {
+		result[len(result)-1-i] = b58Alphabet[digit]
 	}
 
 	return result
 }
 
-// Base58Decode decodes Base58-encoded data.
-func Base58Decode(input []byte) []byte {
-	result := big.NewInt(0)
-	zeroBytes := 0
+// Base58Decode decodes Base58-encoded data. Each leading 1 is decoded as a
+// leading zero byte.
+func Base58Decode(input []byte) ([]byte, error) {
+	zeros := 0
+	for zeros < len(input) && input[zeros] == b58Alphabet[0] {
+		zeros++
+	}
 
-	for b := range input {
-		if b == 0x00 {
-			zeroBytes++
+	// bytes256 holds the bytes of the rest of input, most significant last.
+	// log(58)/log(256) < 0.74 bytes are needed per digit.
+	bytes256 := make([]byte, 0, (len(input)-zeros)*74/100+1)
+	for i, c := range input[zeros:] {
+		carry := b58Indexes[c]
+		if carry < 0 {
+			return nil, fmt.Errorf("base58: invalid character %q at %d", c, zeros+i)
+		}
+		for j := range bytes256 {
+			carry += int(bytes256[j]) * 58
+			bytes256[j] = byte(carry)
+			carry >>= 8
 		}
+		for carry > 0 {
+			bytes256 = append(bytes256, byte(carry))
+			carry >>= 8
+		}
+	}
+
+	result := make([]byte, zeros+len(bytes256))
+	for i, b := range bytes256 {
+		result[len(result)-1-i] = b
+	}
+
+	return result, nil
+}
+
+// Base58CheckEncode encodes payload prefixed with version and followed by
+// its checksum, as addresses and private keys are.
+func Base58CheckEncode(version byte, payload []byte) string {
+	versionedPayload := append([]byte{version}, payload...)
+	fullPayload := append(versionedPayload, checksum(versionedPayload)...)
+
+	return string(Base58Encode(fullPayload))
+}
+
+// Base58CheckDecode decodes data encoded by Base58CheckEncode. It returns
+// the version and the payload once the checksum is verified.
+func Base58CheckDecode(input string) (byte, []byte, error) {
+	fullPayload, err := Base58Decode([]byte(input))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(fullPayload) < 1+addressChecksumLen {
+		return 0, nil, errBase58Length
 	}
 
-	payload := input[zeroBytes:]
-	for _, b := range payload {
-		charIndex := bytes.IndexByte(b58Alphabet, b)
-		result.Mul(result, big.NewInt(58))
-		result.Add(result, big.NewInt(int64(charIndex)))
+	versionedPayload := fullPayload[:len(fullPayload)-addressChecksumLen]
+	if !bytes.Equal(checksum(versionedPayload), fullPayload[len(versionedPayload):]) {
+		return 0, nil, errBase58Checksum
 	}
 
-	decoded := result.Bytes()
-	decoded = append(bytes.Repeat([]byte{byte(0x00)}, zeroBytes), decoded...)
-	return decoded
+	return versionedPayload[0], versionedPayload[1:], nil
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/hex"
 	"strings"
 	"testing"
@@ -18,6 +19,137 @@ func TestBase58(t *testing.T) {
 	encoded := Base58Encode(hash)
 	assert.Equal(t, "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM", string(encoded))
 
-	decoded := Base58Decode([]byte("16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM"))
+	decoded, err := Base58Decode([]byte("16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM"))
+	assert.Nil(t, err)
 	assert.Equal(t, strings.ToLower("00010966776006953D5567439E5E39F86A0D273BEED61967F6"), hex.EncodeToString(decoded))
 }
+
+func TestBase58Vectors(t *testing.T) {
+	vectors := []struct {
+		hex     string
+		encoded string
+	}{
+		{"", ""},
+		{"61", "2g"},
+		{"626262", "a3gV"},
+		{"636363", "aPEr"},
+		{"73696d706c792061206c6f6e6720737472696e67", "2cFupjhnEsSn59qHXstmK2ffpLv2"},
+		{"00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+		{"516b6fcd0f", "ABnLTmg"},
+		{"bf4f89001e670274dd", "3SEo3LWLoPntC"},
+		{"572e4794", "3EFU7m"},
+		{"ecac89cad93923c02321", "EJDM8drfXA6uyA"},
+		{"10c8511e", "Rt5zm"},
+		{"00000000000000000000", "1111111111"},
+		{"000111d38e5fc9071ffcd20b4a763cc9ae4f252bb4e48fd66a835e252ada93ff480d6dd43dc62a641155a5", "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"},
+	}
+
+	for _, v := range vectors {
+		data, err := hex.DecodeString(v.hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, v.encoded, string(Base58Encode(data)), "Encoding %s.", v.hex)
+		decoded, err := Base58Decode([]byte(v.encoded))
+		assert.Nil(t, err, "Decoding %s.", v.encoded)
+		assert.Equal(t, v.hex, hex.EncodeToString(decoded), "Decoding %s.", v.encoded)
+	}
+
+	for _, invalid := range []string{"0", "O", "I", "l", "3mJr0", "3mJr7A+", " 3mJr7A", "3mJr7A\x00", "é"} {
+		_, err := Base58Decode([]byte(invalid))
+		assert.NotNil(t, err, "%q is not Base58.", invalid)
+	}
+}
+
+func TestBase58Check(t *testing.T) {
+	payload, err := hex.DecodeString("010966776006953d5567439e5e39f86a0d273bee")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := Base58CheckEncode(0x00, payload)
+	assert.Equal(t, "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM", encoded)
+
+	version, decoded, err := Base58CheckDecode(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0x00), version)
+	assert.Equal(t, payload, decoded)
+
+	version, decoded, err = Base58CheckDecode(Base58CheckEncode(0xef, nil))
+	assert.Nil(t, err, "A payload may be empty.")
+	assert.Equal(t, byte(0xef), version)
+	assert.Empty(t, decoded)
+
+	_, _, err = Base58CheckDecode("16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvN")
+	assert.Equal(t, errBase58Checksum, err)
+	_, _, err = Base58CheckDecode("16UwLL9Risc3QfPqBUvKofHmBQ7wMtjv0")
+	assert.NotNil(t, err)
+	for _, short := range []string{"", "1", "1111", "2g"} {
+		_, _, err = Base58CheckDecode(short)
+		assert.Equal(t, errBase58Length, err, "%q is too short.", short)
+	}
+
+	for _, address := range []string{"", "1", "1111", "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvN", "0OIl"} {
+		assert.False(t, ValidateAddr(address), "%q is not an address.", address)
+	}
+}
+
+func FuzzBase58(f *testing.F) {
+	for _, seed := range []string{"", "00", "0000ff", "61", "00eb15231dfceb60925886b67d065299925915aeb172c06647"} {
+		data, _ := hex.DecodeString(seed)
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		encoded := Base58Encode(data)
+		decoded, err := Base58Decode(encoded)
+		if err != nil {
+			t.Fatalf("decoding %q: %v", encoded, err)
+		}
+		if !bytes.Equal(data, decoded) {
+			t.Fatalf("%x decoded as %x", data, decoded)
+		}
+	})
+}
+
+func FuzzBase58Decode(f *testing.F) {
+	for _, seed := range []string{"", "1", "11a3gV", "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM", "0OIl", "a3gV+"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		decoded, err := Base58Decode([]byte(s))
+		if err != nil {
+			return
+		}
+		if encoded := Base58Encode(decoded); string(encoded) != s {
+			t.Fatalf("%q decoded as %x encoded as %q", s, decoded, encoded)
+		}
+	})
+}
+
+func FuzzBase58Check(f *testing.F) {
+	f.Add(byte(0x00), []byte{})
+	f.Add(byte(0x6f), []byte{0x01, 0x09, 0x66})
+	f.Add(byte(0xef), bytes.Repeat([]byte{0xff}, 33))
+
+	f.Fuzz(func(t *testing.T, version byte, payload []byte) {
+		encoded := Base58CheckEncode(version, payload)
+		decodedVersion, decoded, err := Base58CheckDecode(encoded)
+		if err != nil {
+			t.Fatalf("decoding %q: %v", encoded, err)
+		}
+		if decodedVersion != version || !bytes.Equal(payload, decoded) {
+			t.Fatalf("%x %x decoded as %x %x", version, payload, decodedVersion, decoded)
+		}
+
+		// Changing any character breaks the checksum.
+		for i := range encoded {
+			c := b58Alphabet[(b58Indexes[encoded[i]]+1)%len(b58Alphabet)]
+			if _, _, err := Base58CheckDecode(encoded[:i] + string(c) + encoded[i+1:]); err == nil {
+				t.Fatalf("%q changed at %d decoded", encoded, i)
+			}
+		}
+	})
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Bech32Variant selects the checksum constant of a Bech32 string. Version 0
+// witness programs use Bech32, later versions Bech32m.
+type Bech32Variant int
+
+// Bech32 variants.
+const (
+	Bech32 Bech32Variant = iota + 1
+	Bech32m
+)
+
+const (
+	bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	// bech32MaxLen is the length of the longest Bech32 string.
+	bech32MaxLen      = 90
+	bech32ChecksumLen = 6
+
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+// bech32Indexes maps characters to their value in bech32Charset, -1 for
+// characters outside of it.
+var bech32Indexes = func() [256]int {
+	var indexes [256]int
+	for i := range indexes {
+		indexes[i] = -1
+	}
+	for i, c := range []byte(bech32Charset) {
+		indexes[c] = i
+	}
+	return indexes
+}()
+
+var errBech32Checksum = errors.New("bech32: checksum mismatch")
+
+func (v Bech32Variant) String() string {
+	switch v {
+	case Bech32:
+		return "bech32"
+	case Bech32m:
+		return "bech32m"
+	}
+	return fmt.Sprintf("Bech32Variant(%d)", int(v))
+}
+
+// constant returns the value the checksum of a string of variant v makes
+// the polymod of.
+func (v Bech32Variant) constant() uint32 {
+	if v == Bech32m {
+		return bech32mConst
+	}
+	return bech32Const
+}
+
+// bech32Polymod computes the BCH checksum of values.
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i, g := range generator {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	return chk
+}
+
+// bech32HRPExpand returns the values the human-readable part contributes to
+// the checksum: the high bits of its characters, a zero, then their low bits.
+func bech32HRPExpand(hrp string) []byte {
+	values := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]>>5)
+	}
+	values = append(values, 0)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]&31)
+	}
+	return values
+}
+
+// Bech32Encode encodes data, made of 5-bit values, with the human-readable
+// part hrp and the checksum of variant.
+func Bech32Encode(hrp string, data []byte, variant Bech32Variant) (string, error) {
+	if len(hrp) == 0 || len(hrp)+1+len(data)+bech32ChecksumLen > bech32MaxLen {
+		return "", fmt.Errorf("bech32: invalid length of %d data values for %q", len(data), hrp)
+	}
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", fmt.Errorf("bech32: invalid character %q in human-readable part", hrp[i])
+		}
+	}
+	if strings.ToLower(hrp) != hrp {
+		return "", errors.New("bech32: human-readable part is not lowercase")
+	}
+	for _, v := range data {
+		if v > 31 {
+			return "", fmt.Errorf("bech32: data value %d exceeds 5 bits", v)
+		}
+	}
+
+	values := append(bech32HRPExpand(hrp), data...)
+	values = append(values, make([]byte, bech32ChecksumLen)...)
+	polymod := bech32Polymod(values) ^ variant.constant()
+
+	var b strings.Builder
+	b.WriteString(hrp)
+	b.WriteByte('1')
+	for _, v := range data {
+		b.WriteByte(bech32Charset[v])
+	}
+	for i := 0; i < bech32ChecksumLen; i++ {
+		b.WriteByte(bech32Charset[(polymod>>uint(5*(bech32ChecksumLen-1-i)))&31])
+	}
+
+	return b.String(), nil
+}
+
+// Bech32Decode decodes a Bech32 or Bech32m string. It returns the
+// human-readable part, in lowercase, the 5-bit data values and the variant
+// the checksum matches.
+func Bech32Decode(s string) (string, []byte, Bech32Variant, error) {
+	if len(s) > bech32MaxLen {
+		return "", nil, 0, fmt.Errorf("bech32: string of %d characters exceeds %d", len(s), bech32MaxLen)
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 33 || s[i] > 126 {
+			return "", nil, 0, fmt.Errorf("bech32: invalid character %q at %d", s[i], i)
+		}
+	}
+	lower := strings.ToLower(s)
+	if lower != s && strings.ToUpper(s) != s {
+		return "", nil, 0, errors.New("bech32: mixed case")
+	}
+
+	sep := strings.LastIndexByte(lower, '1')
+	if sep < 1 || sep+1+bech32ChecksumLen > len(lower) {
+		return "", nil, 0, errors.New("bech32: missing separator, human-readable part or checksum")
+	}
+	hrp := lower[:sep]
+
+	data := make([]byte, 0, len(lower)-sep-1)
+	for i := sep + 1; i < len(lower); i++ {
+		v := bech32Indexes[lower[i]]
+		if v < 0 {
+			return "", nil, 0, fmt.Errorf("bech32: invalid character %q at %d", lower[i], i)
+		}
+		data = append(data, byte(v))
+	}
+
+	var variant Bech32Variant
+	switch bech32Polymod(append(bech32HRPExpand(hrp), data...)) {
+	case bech32Const:
+		variant = Bech32
+	case bech32mConst:
+		variant = Bech32m
+	default:
+		return "", nil, 0, errBech32Checksum
+	}
+
+	return hrp, data[:len(data)-bech32ChecksumLen], variant, nil
+}
+
+// convertBits regroups data from fromBits-bit to toBits-bit values. If pad
+// is true, the last value is padded with zeros, otherwise the leftover bits
+// must be fewer than fromBits and zero.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var (
+		acc    uint32
+		bits   uint
+		result []byte
+	)
+	maxValue := uint32(1)<<toBits - 1
+
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, fmt.Errorf("bech32: value %d exceeds %d bits", v, fromBits)
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&maxValue))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, errors.New("bech32: invalid padding")
+	}
+
+	return result, nil
+}
+
+// encodeWitnessAddress returns the Bech32 address of a witness program of
+// version with the human-readable part hrp. Version 0 programs use Bech32,
+// later versions Bech32m.
+func encodeWitnessAddress(hrp string, version byte, program []byte) (string, error) {
+	if err := checkWitnessProgram(version, program); err != nil {
+		return "", err
+	}
+
+	variant := Bech32
+	if version > 0 {
+		variant = Bech32m
+	}
+	data, err := convertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	return Bech32Encode(hrp, append([]byte{version}, data...), variant)
+}
+
+// decodeWitnessAddress returns the version and the program of a Bech32
+// address with the human-readable part hrp.
+func decodeWitnessAddress(hrp, address string) (byte, []byte, error) {
+	decodedHRP, data, variant, err := Bech32Decode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if decodedHRP != hrp {
+		return 0, nil, fmt.Errorf("bech32: human-readable part %q is not %q", decodedHRP, hrp)
+	}
+	if len(data) == 0 {
+		return 0, nil, errors.New("bech32: missing witness version")
+	}
+
+	version := data[0]
+	if (version == 0) != (variant == Bech32) {
+		return 0, nil, fmt.Errorf("bech32: witness version %d cannot use %s", version, variant)
+	}
+	program, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := checkWitnessProgram(version, program); err != nil {
+		return 0, nil, err
+	}
+
+	return version, program, nil
+}
+
+// checkWitnessProgram checks the version and the length of a witness
+// program. Version 0 programs are the hash of a public key or of a script.
+func checkWitnessProgram(version byte, program []byte) error {
+	if version > 16 {
+		return fmt.Errorf("bech32: invalid witness version %d", version)
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return fmt.Errorf("bech32: invalid witness program length %d", len(program))
+	}
+	if version == 0 && len(program) != addressHashLen && len(program) != witnessScriptHashLen {
+		return fmt.Errorf("bech32: invalid version 0 witness program length %d", len(program))
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBech32(t *testing.T) {
+	valid := map[string]Bech32Variant{
+		"A12UEL5L": Bech32,
+		"a12uel5l": Bech32,
+		"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs": Bech32,
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw":                                              Bech32,
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w":                               Bech32,
+		"?1ezyfcl": Bech32,
+		"A1LQFN3A": Bech32m,
+		"a1lqfn3a": Bech32m,
+		"an83characterlonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11sg7hg6": Bech32m,
+		"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx":                                              Bech32m,
+		"split1checkupstagehandshakeupstreamerranterredcaperredlc445v":                               Bech32m,
+		"?1v759aa": Bech32m,
+	}
+	valid["11"+strings.Repeat("q", 82)+"c8247j"] = Bech32
+	valid["11"+strings.Repeat("l", 82)+"ludsr8"] = Bech32m
+	for s, variant := range valid {
+		hrp, data, decodedVariant, err := Bech32Decode(s)
+		if !assert.Nil(t, err, "Decoding %s.", s) {
+			continue
+		}
+		assert.Equal(t, variant, decodedVariant, "Variant of %s.", s)
+
+		encoded, err := Bech32Encode(hrp, data, decodedVariant)
+		assert.Nil(t, err)
+		assert.Equal(t, strings.ToLower(s), encoded, "Encoding %s.", s)
+	}
+
+	invalid := []string{
+		"\x201nwldj5",
+		"\x7f1axkwrx",
+		"\x801eym55h",
+		"an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx",
+		"pzry9x0s0muk",
+		"1pzry9x0s0muk",
+		"x1b4n0q5v",
+		"li1dgmt3",
+		"de1lg7wt\xff",
+		"A1G7SGD8",
+		"10a06t8",
+		"1qzzfhee",
+		"a12UEL5L",
+	}
+	for _, s := range invalid {
+		_, _, _, err := Bech32Decode(s)
+		assert.NotNil(t, err, "%q is not valid.", s)
+	}
+}
+
+func TestWitnessAddress(t *testing.T) {
+	valid := map[string]string{
+		"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4":                                 "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+		"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7":             "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262",
+		"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y": "5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6",
+		"BC1SW50QGDZ25J":                       "6002751e",
+		"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs": "5210751e76e8199196d454941c45d1b3a323",
+		"tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy": "0020000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433",
+		"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c": "5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433",
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0": "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+	}
+	for address, scriptHex := range valid {
+		hrp := strings.ToLower(address[:2])
+		version, program, err := decodeWitnessAddress(hrp, address)
+		if !assert.Nil(t, err, "Decoding %s.", address) {
+			continue
+		}
+
+		script := []byte{0}
+		if version > 0 {
+			script[0] = OP_1 + version - 1
+		}
+		script = append(script, byte(len(program)))
+		script = append(script, program...)
+		assert.Equal(t, scriptHex, hex.EncodeToString(script), "Program of %s.", address)
+
+		version, program, ok := extractWitnessProgram(script)
+		assert.True(t, ok)
+		encoded, err := encodeWitnessAddress(hrp, version, program)
+		assert.Nil(t, err)
+		assert.Equal(t, strings.ToLower(address), encoded)
+	}
+
+	invalid := map[string]string{
+		"tc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq5zuyut": "tb",
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd": "bc",
+		"tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf": "tb",
+		"BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL": "bc",
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh":                     "bc",
+		"tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47": "tb",
+		"bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4": "bc",
+		"BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R": "bc",
+		"bc1pw5dgrnzv": "bc",
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v8n0nx0muaewav253zgeav": "bc",
+		"BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P":                                         "bc",
+		"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq47Zagq":               "tb",
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7v07qwwzcrf":             "bc",
+		"tb1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vpggkg4j":               "tb",
+		"bc1gmk9yu": "bc",
+	}
+	for address, hrp := range invalid {
+		_, _, err := decodeWitnessAddress(hrp, address)
+		assert.NotNil(t, err, "%s is not valid.", address)
+	}
+}
+
+func TestBech32Address(t *testing.T) {
+	activeNet = &RegTestParams
+	defer func() { activeNet = &MainNetParams }()
+
+	wallet := NewWallet()
+	address := string(wallet.GetBech32Address())
+	assert.True(t, strings.HasPrefix(address, "bcrt1q"))
+	assert.True(t, ValidateAddr(address))
+	assert.True(t, ValidateAddr(strings.ToUpper(address)), "Bech32 addresses may be uppercase.")
+
+	script, err := PayToAddrScript(address)
+	assert.Nil(t, err)
+	assert.Equal(t, PayToWitnessPubKeyHashScript(HashPubKey(wallet.PublicKey)), script)
+	assert.Equal(t, address, ExtractAddress(script))
+	assert.Equal(t, WitnessV0PubKeyHashTy, GetOutputType(script))
+
+	out := NewTXOutput(1, address)
+	assert.True(t, out.IsLockedWithKey(HashPubKey(wallet.PublicKey)))
+
+	wallets := &Wallets{Wallets: map[string]*Wallet{string(wallet.GetAddress()): wallet}}
+	assert.Equal(t, wallet, wallets.FindAddress(address))
+	assert.Equal(t, wallet, wallets.FindAddress(string(wallet.GetAddress())))
+	assert.Nil(t, wallets.FindAddress(string(NewWallet().GetBech32Address())))
+
+	scriptAddress := WitnessScriptHashAddress([]byte{OP_1})
+	script, err = PayToAddrScript(scriptAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, WitnessV0ScriptHashTy, GetOutputType(script))
+	assert.Equal(t, scriptAddress, ExtractAddress(script))
+
+	future, err := encodeWitnessAddress(activeNet.Bech32HRP, 1, HashPubKey(wallet.PublicKey))
+	assert.Nil(t, err)
+	assert.False(t, ValidateAddr(future), "Witness versions other than 0 are not supported.")
+
+	typo := []byte(address)
+	typo[10] = bech32Charset[(bech32Indexes[typo[10]]+1)%len(bech32Charset)]
+	for _, invalid := range []string{string(typo), address[:len(address)-1], "bcrt1", address + "q"} {
+		assert.False(t, ValidateAddr(invalid), "%s is not valid.", invalid)
+	}
+}
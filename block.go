@@ -7,6 +7,10 @@ import (
 	"time"
 )
 
+// clock returns the current time used to timestamp new blocks. Tests replace
+// it with a fixed clock to produce reproducible chains.
+var clock = time.Now
+
 // A Block is composed of headers and transactons. This is a
 // simplified and mixed datastructure.
 type Block struct {
@@ -18,22 +22,40 @@ type Block struct {
 	Height        int
 }
 
-// HashTransactions returns a hash of transactions in the block.
+// HashTransactions returns a hash of transactions in the block: the Merkle
+// root of their txids.
 func (b *Block) HashTransactions() []byte {
 	var transactions [][]byte
 
 	for _, tx := range b.Transactions {
-		transactions = append(transactions, tx.Serialize())
+		transactions = append(transactions, tx.ID)
 	}
 	mTree := NewMerkleTree(transactions)
 
 	return mTree.RootNode.Data
 }
 
+// HashWitnesses returns the Merkle root of the wtxids of transactions in the
+// block, committing to their witnesses.
+func (b *Block) HashWitnesses() []byte {
+	var witnesses [][]byte
+
+	for _, tx := range b.Transactions {
+		witnesses = append(witnesses, tx.WitnessID())
+	}
+	mTree := NewMerkleTree(witnesses)
+
+	return mTree.RootNode.Data
+}
+
 // NewBlock creates a block with block data and previous block hash and returns it.
 func NewBlock(transactions []*Transaction, prevBlockHash []byte, height int) *Block {
+	return newBlock(clock().Unix(), transactions, prevBlockHash, height)
+}
+
+func newBlock(timestamp int64, transactions []*Transaction, prevBlockHash []byte, height int) *Block {
 	block := &Block{
-		Timestamp:     time.Now().Unix(),
+		Timestamp:     timestamp,
 		Transactions:  transactions,
 		PrevBlockHash: prevBlockHash,
 		Hash:          []byte{}, // hash will be calculated block itself.
@@ -51,7 +73,7 @@ func NewBlock(transactions []*Transaction, prevBlockHash []byte, height int) *Bl
 
 // NewGenesisBlock creates and returns genesis Block.
 func NewGenesisBlock(coinbase *Transaction) *Block {
-	return NewBlock([]*Transaction{coinbase}, []byte{}, 0)
+	return newBlock(activeNet.GenesisTimestamp, []*Transaction{coinbase}, []byte{}, 0)
 }
 
 // Serialize encodes a block struct into gob data.
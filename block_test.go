@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegtestChainIsReproducible(t *testing.T) {
+	activeNet = &RegTestParams
+	clock = func() time.Time { return time.Unix(1500000000, 0) }
+	defer func() {
+		activeNet = &MainNetParams
+		clock = time.Now
+	}()
+
+	address := "mfcSEPR8EkJrpX91YkTJ9iscdAzppJrG9j"
+	mine := func() []*Block {
+		genesis := NewGenesisBlock(NewCoinbaseTX(address, RegTestParams.GenesisCoinbaseData))
+		next := NewBlock([]*Transaction{NewCoinbaseTX(address, "Block 1")}, genesis.Hash, 1)
+		return []*Block{genesis, next}
+	}
+
+	first := mine()
+	second := mine()
+
+	assert.Equal(t, RegTestParams.GenesisTimestamp, first[0].Timestamp, "Genesis block has a fixed timestamp.")
+	assert.Equal(t, int64(1500000000), first[1].Timestamp, "Block is timestamped by the injected clock.")
+	for i := range first {
+		assert.Equal(t, first[i].Hash, second[i].Hash, "Block hashes are reproducible.")
+		assert.True(t, NewProofOfWork(first[i]).Validate(), "Block PoW is valid.")
+	}
+}
+
+func TestBlockCommitsToWitnesses(t *testing.T) {
+	activeNet = &RegTestParams
+	defer func() { activeNet = &MainNetParams }()
+
+	wallet := NewWallet()
+	prevTx := NewCoinbaseTX(string(wallet.GetAddress()), "Block 1")
+	tx := &Transaction{
+		Vin:  []TXInput{{Txid: prevTx.ID, Vout: 0, Sequence: sequenceFinal}},
+		Vout: []TXOutput{*NewTXOutput(10, string(wallet.GetAddress()))},
+	}
+	tx.ID = tx.ComputeID()
+	tx.Sign(wallet.PrivateKey, map[string]Transaction{hex.EncodeToString(prevTx.ID): *prevTx})
+
+	block := NewBlock([]*Transaction{NewCoinbaseTX(string(wallet.GetAddress()), "Block 2"), tx}, []byte{0x01}, 2)
+	assert.True(t, NewProofOfWork(block).Validate())
+	txRoot, witnessRoot := block.HashTransactions(), block.HashWitnesses()
+	header := NewProofOfWork(block).prepareData(block.Nonce)
+
+	// Replace the witness, as a relaying node could.
+	tx.Vin[0].ScriptSig = NewScriptBuilder().AddData(tx.Vin[0].ScriptSig).Script()
+	assert.Equal(t, tx.ID, tx.ComputeID(), "The txid does not cover witnesses.")
+	assert.Equal(t, txRoot, block.HashTransactions())
+	assert.NotEqual(t, witnessRoot, block.HashWitnesses())
+	assert.NotEqual(t, header, NewProofOfWork(block).prepareData(block.Nonce), "The block commits to witnesses.")
+}
@@ -12,11 +12,7 @@ import (
 	bolt "go.etcd.io/bbolt"
 )
 
-const (
-	dbFile              = "blockchain_%s.db"
-	blocksBucket        = "blocks"
-	genesisCoinbaseData = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
-)
+const blocksBucket = "blocks"
 
 // Blockchain represents a chain of blocks.
 // It’s an ordered, back-linked list. Which means that blocks are stored in the insertion order and that each block is linked to the previous one.
@@ -55,6 +51,12 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 		log.Panic(err)
 	}
 
+	// Locked transactions cannot be included before their time.
+	err = checkLocks(transactions, lastHeight+1, clock().Unix(), UTXOSet{bc}.findMined)
+	if err != nil {
+		log.Panic(err)
+	}
+
 	newBlock := NewBlock(transactions, lastHash, lastHeight+1)
 
 	err = bc.db.Update(func(tx *bolt.Tx) error {
@@ -72,7 +74,7 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 		}
 		bc.tip = newBlock.Hash
 
-		return nil
+		return removePending(tx, newBlock)
 	})
 	if err != nil {
 		log.Panic(err)
@@ -82,6 +84,7 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 }
 
 // FindUTXO finds and returns all unspent transaction outputs and returns transactions with spent outputs removed.
+// Unspendable outputs are left out.
 func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 	UTXO := make(map[string]TXOutputs)
 	spentTXOs := make(map[string][]int)
@@ -95,6 +98,10 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 
 		Outputs:
 			for outIdx, out := range tx.Vout {
+				if isUnspendable(out.ScriptPubKey) {
+					continue
+				}
+
 				// Was the output spent?
 				if spentTXOs[txID] != nil {
 					for _, spentOutIdx := range spentTXOs[txID] {
@@ -106,6 +113,9 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 
 				outs := UTXO[txID]
 				outs.Outputs = append(outs.Outputs, out)
+				outs.Indexes = append(outs.Indexes, outIdx)
+				outs.Height, outs.Time, outs.Coinbase = block.Height, block.Timestamp, tx.IsCoinbase()
+				UTXO[txID] = outs
 			}
 
 			if tx.IsCoinbase() == false {
@@ -124,6 +134,48 @@ func (bc *Blockchain) FindUTXO() map[string]TXOutputs {
 	return UTXO
 }
 
+// FindUsedPubKeyHashes returns the set of public key hashes outputs were
+// ever locked to.
+func (bc *Blockchain) FindUsedPubKeyHashes() map[string]bool {
+	used := make(map[string]bool)
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			for _, out := range tx.Vout {
+				if pubKeyHash := extractPubKeyHash(scriptCode(out.ScriptPubKey)); pubKeyHash != nil {
+					used[string(pubKeyHash)] = true
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return used
+}
+
+// FindAnchors returns the anchors of the data outputs of every block.
+func (bc *Blockchain) FindAnchors() []Anchor {
+	var anchors []Anchor
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+		anchors = append(anchors, blockAnchors(block)...)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return anchors
+}
+
 // FindTransaction finds a transaction by its ID.
 func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 	bci := bc.Iterator()
@@ -143,8 +195,70 @@ func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 	return Transaction{}, errors.New("transaction is not found")
 }
 
+// findMinedFrom finds where the transaction with ID was mined in the chain
+// ending with the block blockHash.
+func (bc *Blockchain) findMinedFrom(ID, blockHash []byte) (minedTx, error) {
+	bci := &BlockchainIterator{blockHash, bc.db}
+
+	for {
+		block := bci.Next()
+		for _, tx := range block.Transactions {
+			if bytes.Compare(tx.ID, ID) == 0 {
+				return minedTx{block.Height, block.Timestamp}, nil
+			}
+		}
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return minedTx{}, errors.New("transaction is not found")
+}
+
+// findBranch returns the blocks of the branch ending with block since it
+// forked from the chain, from the oldest. It fails if a previous block is not
+// known.
+func (bc *Blockchain) findBranch(block *Block) ([]*Block, error) {
+	main, err := bc.GetBlock(bc.tip)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := []*Block{block}
+	for {
+		prev, err := bc.GetBlock(branch[0].PrevBlockHash)
+		if err != nil {
+			return nil, fmt.Errorf("previous block %x is not known", branch[0].PrevBlockHash)
+		}
+		for main.Height > prev.Height {
+			main, err = bc.GetBlock(main.PrevBlockHash)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if bytes.Equal(main.Hash, prev.Hash) {
+			return branch, nil
+		}
+		branch = append([]*Block{&prev}, branch...)
+	}
+}
+
 // SignTransaction signs inputs of a Transaction.
 func (bc *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey) {
+	tx.Sign(privKey, bc.findPrevTransactions(tx))
+}
+
+// SignWalletTransaction signs each input of a Transaction with the key of
+// wallets the output it spends is locked to.
+func (bc *Blockchain) SignWalletTransaction(tx *Transaction, wallets *Wallets) {
+	err := tx.SignWithWallets(wallets, bc.findPrevTransactions(tx))
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// findPrevTransactions finds the transactions whose outputs tx spends.
+func (bc *Blockchain) findPrevTransactions(tx *Transaction) map[string]Transaction {
 	prevTXs := make(map[string]Transaction)
 
 	for _, vin := range tx.Vin {
@@ -155,7 +269,7 @@ func (bc *Blockchain) SignTransaction(tx *Transaction, privKey ecdsa.PrivateKey)
 		prevTXs[hex.EncodeToString(prevTx.ID)] = prevTx
 	}
 
-	tx.Sign(privKey, prevTXs)
+	return prevTXs
 }
 
 // VerifyTransaction verifies transaction input signatures.
@@ -164,20 +278,10 @@ func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
 		return true
 	}
 
-	prevTXs := make(map[string]Transaction)
-
-	for _, vin := range tx.Vin {
-		prevTx, err := bc.FindTransaction(vin.Txid)
-		if err != nil {
-			log.Panic(err)
-		}
-		prevTXs[hex.EncodeToString(prevTx.ID)] = prevTx
-	}
-
-	return tx.Verify(prevTXs)
+	return tx.Verify(bc.findPrevTransactions(tx))
 }
 
-func dbExists() bool {
+func dbExists(dbFile string) bool {
 	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
 		return false
 	}
@@ -245,7 +349,17 @@ func (bc *Blockchain) GetBlock(blockHash []byte) (Block, error) {
 }
 
 // AddBlock saves the block into the blockchain
+// A block that would become the tip is rejected if it, or a block of its
+// branch, includes transactions that are still locked.
 func (bc *Blockchain) AddBlock(block *Block) {
+	if block.Height > bc.GetBestHeight() {
+		err := bc.checkBlockLocks(block)
+		if err != nil {
+			fmt.Printf("Rejected block %x: %v\n", block.Hash, err)
+			return
+		}
+	}
+
 	err := bc.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		blockInDb := b.Get(block.Hash)
@@ -270,6 +384,8 @@ func (bc *Blockchain) AddBlock(block *Block) {
 				log.Panic(err)
 			}
 			bc.tip = block.Hash
+
+			return removePending(tx, block)
 		}
 
 		return nil
@@ -282,8 +398,8 @@ func (bc *Blockchain) AddBlock(block *Block) {
 // NewBlockChain returns a new blockchain with genesis block.
 // A db connection included in the returned value is intended to be reused.
 func NewBlockChain(nodeID string) *Blockchain {
-	dbFile := fmt.Sprintf(dbFile, nodeID)
-	if dbExists() == false {
+	dbFile := fmt.Sprintf(activeNet.DBFile, nodeID)
+	if dbExists(dbFile) == false {
 		fmt.Println("No existing blockchain found. Create one first.")
 		os.Exit(1)
 	}
@@ -309,8 +425,8 @@ func NewBlockChain(nodeID string) *Blockchain {
 // CreateBlockChain creates a new blockchain.
 // It takes an address which will receive the reward for mining the genesis block.
 func CreateBlockChain(address, nodeID string) *Blockchain {
-	dbFile := fmt.Sprintf(dbFile, nodeID)
-	if dbExists() {
+	dbFile := fmt.Sprintf(activeNet.DBFile, nodeID)
+	if dbExists(dbFile) {
 		fmt.Println("Blockchain already exists.")
 		os.Exit(1)
 	}
@@ -322,7 +438,7 @@ func CreateBlockChain(address, nodeID string) *Blockchain {
 	}
 
 	err = db.Update(func(tx *bolt.Tx) error {
-		cbtx := NewCoinbaseTX(address, genesisCoinbaseData)
+		cbtx := NewCoinbaseTX(address, activeNet.GenesisCoinbaseData)
 		genesis := NewGenesisBlock(cbtx)
 
 		b, err := tx.CreateBucket([]byte(blocksBucket))
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // CLI represents command line.
@@ -24,7 +30,7 @@ func (cli *CLI) createBlockChain(address, nodeID string) {
 	fmt.Println("Done creating blockchain.")
 }
 
-func (cli *CLI) getBalance(address, nodeID string) {
+func (cli *CLI) getBalance(address string, minConf int, nodeID string) {
 	if !ValidateAddr(address) {
 		log.Panic("error: address is not valid")
 	}
@@ -33,39 +39,552 @@ func (cli *CLI) getBalance(address, nodeID string) {
 	defer bc.db.Close()
 
 	// The account balance is the sum of values of all unspent transaction outputs locked by the account address.
-	balance := 0
-	pubKeyHash := Base58Decode([]byte(address))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	UTXOs := UTXOSet.FindUTXO(pubKeyHash)
-
-	for _, out := range UTXOs {
-		balance += out.Value
+	lockScript, err := PayToAddrScript(address)
+	if err != nil {
+		log.Panic(err)
+	}
+	lockScripts := [][]byte{lockScript}
+	// A key of the wallet file also holds the outputs paying to its other
+	// address.
+	wallets, err := NewWallets(nodeID)
+	if err != nil && !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+	if err == nil {
+		if wallet := wallets.FindAddress(address); wallet != nil {
+			lockScripts = wallet.LockScripts()
+		}
 	}
+	balance := UTXOSet.GetBalance(minConf, lockScripts...)
 
-	fmt.Printf("Balance of %q: %d\n", address, balance)
+	fmt.Printf("Balance of %q: %d\n", address, balance.Confirmed)
+	fmt.Printf("    Unconfirmed: %d\n", balance.Unconfirmed)
+	fmt.Printf("    Immature: %d\n", balance.Immature)
 }
 
-func (cli *CLI) createWallet(nodeID string) {
+func (cli *CLI) createWallet(bech32 bool, nodeID string) {
 	wallets, err := NewWallets(nodeID)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		log.Panic(err)
 	}
+
+	// A new wallet file derives all its keys from a fresh seed.
+	if os.IsNotExist(err) {
+		mnemonic, err := NewMnemonic()
+		if err != nil {
+			log.Panic(err)
+		}
+		err = wallets.SetHDSeed(mnemonic)
+		if err != nil {
+			log.Panic(err)
+		}
+		fmt.Println("Write down your recovery phrase, it restores every address of the wallet file:")
+		fmt.Println(mnemonic)
+	}
+
 	address := wallets.CreateWallet()
 	wallets.SaveToFile(nodeID)
+	if bech32 {
+		address = string(wallets.Wallets[address].GetBech32Address())
+	}
 
 	fmt.Printf("Your new address: %s\n", address)
 }
 
-func (cli *CLI) listAllAddrs(nodeID string) {
+func (cli *CLI) restoreWallet(mnemonic, nodeID string) {
 	wallets, err := NewWallets(nodeID)
+	if err == nil {
+		log.Panic("error: wallet file already exists")
+	}
+	if !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+
+	err = wallets.SetHDSeed(mnemonic)
 	if err != nil {
 		log.Panic(err)
 	}
-	addresses := wallets.GetAddrs()
+
+	if dbExists(fmt.Sprintf(activeNet.DBFile, nodeID)) {
+		bc := NewBlockChain(nodeID)
+		defer bc.db.Close()
+
+		for _, address := range wallets.Rescan(bc) {
+			fmt.Printf("Found used address: %s\n", address)
+		}
+	}
+	if len(wallets.Wallets) == 0 {
+		wallets.CreateWallet()
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Printf("Wallet restored with %d addresses.\n", len(wallets.Wallets))
+}
+
+func (cli *CLI) encryptWallet(passphrase, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = wallets.Encrypt(passphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Println("Wallet encrypted. Unlock it with walletpassphrase before sending coins or creating addresses.")
+}
+
+func (cli *CLI) walletPassphrase(passphrase string, timeout int, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = wallets.Unlock(passphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Wallet unlocked for %d seconds while this command runs.\n", timeout)
+	err = wallets.ServeUnlockSession(nodeID, time.Duration(timeout)*time.Second)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println("Wallet locked.")
+}
+
+func (cli *CLI) walletLock(nodeID string) {
+	err := RemoveUnlockSession(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println("Wallet locked.")
+}
+
+func (cli *CLI) changePassphrase(oldPassphrase, newPassphrase, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = wallets.ChangePassphrase(oldPassphrase, newPassphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Println("Passphrase changed.")
+}
+
+func (cli *CLI) dumpPrivKey(address, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	wif, err := wallets.DumpPrivKey(address)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(wif)
+}
+
+func (cli *CLI) importPrivKey(wif, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil && !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+	address, err := wallets.ImportPrivKey(wif)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Printf("Imported %s\n", address)
+	cli.rescanAddresses(wallets, []string{address}, nodeID)
+}
+
+func (cli *CLI) vanity(prefix string, ignoreCase bool, nodeID string) {
+	search, err := NewVanitySearch(prefix, ignoreCase)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets, err := NewWallets(nodeID)
+	if err != nil && !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+	if wallets.IsLocked() {
+		log.Panic(errWalletLocked)
+	}
+
+	difficulty := search.Difficulty()
+	fmt.Printf("Searching for an address starting with %s, about %.0f keys to try\n", prefix, difficulty)
+	start := time.Now()
+	wallet := search.Run(func(attempts uint64) {
+		rate := float64(attempts) / time.Since(start).Seconds()
+		fmt.Printf("Tried %d keys at %.0f keys/s, %.1f%% of the expected keys\n", attempts, rate, 100*float64(attempts)/difficulty)
+	})
+
+	address, err := wallets.ImportKey(wallet)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Printf("Found %s in %s\n", address, time.Since(start).Round(time.Millisecond))
+}
+
+func (cli *CLI) backupWallet(path, nodeID string) {
+	err := BackupWallet(nodeID, path)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Wallet backed up to %s\n", path)
+}
+
+func (cli *CLI) importWallet(path, passphrase, nodeID string) {
+	backup, err := LoadBackup(path)
+	if err != nil {
+		log.Panic(err)
+	}
+	if backup.IsEncrypted() {
+		err = backup.Unlock(passphrase)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	wallets, err := NewWallets(nodeID)
+	if err != nil && !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+	imported, err := wallets.ImportWallets(backup)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Printf("Imported %d addresses.\n", len(imported))
+	cli.rescanAddresses(wallets, imported, nodeID)
+}
+
+// rescanAddresses reports the unspent outputs of addresses added to
+// wallets, if the node has a blockchain. The outputs of a key count those
+// paying to its Base58 and Bech32 addresses.
+func (cli *CLI) rescanAddresses(wallets *Wallets, addresses []string, nodeID string) {
+	if len(addresses) == 0 || !dbExists(fmt.Sprintf(activeNet.DBFile, nodeID)) {
+		return
+	}
+	bc := NewBlockChain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
 
 	for _, address := range addresses {
-		fmt.Println(address)
+		lockScript, err := PayToAddrScript(address)
+		if err != nil {
+			log.Panic(err)
+		}
+		lockScripts := [][]byte{lockScript}
+		if wallet := wallets.FindAddress(address); wallet != nil {
+			lockScripts = wallet.LockScripts()
+		}
+		coins := UTXOSet.FindCoins(lockScripts...)
+		fmt.Printf("Found %d unspent outputs worth %d for %s\n", len(coins), coinsValue(coins), address)
+	}
+}
+
+// addressJSON is the JSON form of an address of the wallet file.
+type addressJSON struct {
+	Address     string `json:"address"`
+	Label       string `json:"label,omitempty"`
+	Note        string `json:"note,omitempty"`
+	Created     int64  `json:"created,omitempty"`
+	Change      bool   `json:"change"`
+	WatchOnly   bool   `json:"watchOnly"`
+	Balance     int    `json:"balance"`
+	Unconfirmed int    `json:"unconfirmed"`
+	Immature    int    `json:"immature"`
+}
+
+func (cli *CLI) listAllAddrs(label string, asJSON bool, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	infos := wallets.ListAddresses(label)
+
+	if !asJSON {
+		for _, info := range infos {
+			line := info.Address
+			if info.WatchOnly {
+				line += " (watch-only)"
+			}
+			if info.Label != "" {
+				line += fmt.Sprintf(" %q", info.Label)
+			}
+			fmt.Println(line)
+		}
+		return
+	}
+
+	// Balances are only known to nodes with a blockchain.
+	var utxos *UTXOSet
+	if dbExists(fmt.Sprintf(activeNet.DBFile, nodeID)) {
+		bc := NewBlockChain(nodeID)
+		defer bc.db.Close()
+		utxos = &UTXOSet{bc}
+	}
+
+	decoded := []addressJSON{}
+	for _, info := range infos {
+		entry := addressJSON{
+			Address:   info.Address,
+			Label:     info.Label,
+			Note:      info.Note,
+			Created:   info.Created,
+			Change:    info.Change,
+			WatchOnly: info.WatchOnly,
+		}
+		if utxos != nil {
+			lockScript, err := PayToAddrScript(info.Address)
+			if err != nil {
+				log.Panic(err)
+			}
+			lockScripts := [][]byte{lockScript}
+			if wallet := wallets.FindAddress(info.Address); wallet != nil {
+				lockScripts = wallet.LockScripts()
+			}
+			balance := utxos.GetBalance(1, lockScripts...)
+			entry.Balance, entry.Unconfirmed, entry.Immature = balance.Confirmed, balance.Unconfirmed, balance.Immature
+		}
+		decoded = append(decoded, entry)
+	}
+
+	data, err := json.MarshalIndent(decoded, "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Println(string(data))
+}
+
+func (cli *CLI) setLabel(address, label, note, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = wallets.SetLabel(address, label, note)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Printf("Label of %s set to %q\n", address, label)
+}
+
+func (cli *CLI) getPubKey(address, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallet := wallets.FindAddress(address)
+	if wallet == nil {
+		log.Panic("error: address is not in the wallet file")
+	}
+
+	fmt.Printf("%x\n", wallet.PublicKey)
+}
+
+func (cli *CLI) signMessage(address, message, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallet := wallets.FindAddress(address)
+	if wallet == nil {
+		log.Panic("error: address is not in the wallet file")
+	}
+	signature, err := wallet.SignMessage(message)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(signature)
+}
+
+func (cli *CLI) verifyMessage(address, signature, message string) {
+	valid, err := VerifyMessage(address, signature, message)
+	if err != nil {
+		log.Panic(err)
 	}
+	if !valid {
+		fmt.Println("Signature is not valid.")
+		os.Exit(1)
+	}
+
+	fmt.Println("Signature is valid.")
+}
+
+func (cli *CLI) createMultiSig(nRequired int, keys []string, nodeID string) {
+	var pubKeys [][]byte
+
+	for _, key := range keys {
+		// Keys of the wallet file may be given by their address.
+		if ValidateAddr(key) {
+			wallets, err := NewWallets(nodeID)
+			if err != nil {
+				log.Panic(err)
+			}
+			if pubKey := wallets.WatchOnly[key]; pubKey != nil {
+				pubKeys = append(pubKeys, pubKey)
+				continue
+			}
+			wallet := wallets.FindAddress(key)
+			if wallet == nil {
+				log.Panicf("error: the public key of %s is not in the wallet file", key)
+			}
+			pubKeys = append(pubKeys, wallet.PublicKey)
+			continue
+		}
+
+		pubKey, err := hex.DecodeString(key)
+		if err != nil {
+			log.Panic(err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	redeemScript, err := MultiSigScript(nRequired, pubKeys)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Address: %s\n", ScriptHashAddress(redeemScript))
+	fmt.Printf("Bech32 address: %s\n", WitnessScriptHashAddress(redeemScript))
+	fmt.Printf("Redeem script: %x\n", redeemScript)
+}
+
+func (cli *CLI) spendMultiSig(redeemScriptHex, to string, amount int, bech32 bool, nodeID string) {
+	if !ValidateAddr(to) {
+		log.Panic("error: address is not valid")
+	}
+	redeemScript, err := hex.DecodeString(redeemScriptHex)
+	if err != nil {
+		log.Panic(err)
+	}
+	if _, _, ok := extractMultiSig(redeemScript); !ok {
+		log.Panic("error: redeem script is not a multisig script")
+	}
+
+	bc := NewBlockChain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	lockScript := PayToScriptHashScript(HashPubKey(redeemScript))
+	if bech32 {
+		lockScript = PayToWitnessScriptHashScript(WitnessScriptHash(redeemScript))
+	}
+	ptx, err := NewScriptSpendTransaction(lockScript, redeemScript, to, amount, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("%x\n", ptx.Serialize())
+}
+
+func (cli *CLI) createRawTransaction(inputs []OutPoint, payments []Payment, lockTime uint32, redeemScripts [][]byte, nodeID string) {
+	bc := NewBlockChain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	ptx, err := NewRawTransaction(inputs, payments, lockTime, redeemScripts, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("%x\n", ptx.Serialize())
+}
+
+func (cli *CLI) decodeRawTransaction(txHex string) {
+	decoded, err := json.MarshalIndent(decodePartialTransaction(txHex), "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(string(decoded))
+}
+
+func (cli *CLI) signRawTransaction(txHex, sigHash, nodeID string) {
+	ptx := decodePartialTransaction(txHex)
+	hashType, err := ParseSigHashType(sigHash)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	signed, err := ptx.Sign(wallets, hashType)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("%x\n", ptx.Serialize())
+	fmt.Printf("Signatures added: %d\n", signed)
+	fmt.Printf("Complete: %t\n", ptx.IsComplete())
+}
+
+func (cli *CLI) combineSignatures(txHexes []string) {
+	ptx := decodePartialTransaction(txHexes[0])
+	for _, txHex := range txHexes[1:] {
+		err := ptx.Combine(decodePartialTransaction(txHex))
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	fmt.Printf("%x\n", ptx.Serialize())
+	fmt.Printf("Complete: %t\n", ptx.IsComplete())
+}
+
+func (cli *CLI) sendRawTransaction(txHex, minerAddr, nodeID string) {
+	tx, err := decodePartialTransaction(txHex).Finalize()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if minerAddr != "" && !ValidateAddr(minerAddr) {
+		log.Panic("error: address is not valid")
+	}
+	bc := NewBlockChain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	if minerAddr != "" {
+		height := bc.GetBestHeight() + 1
+		cbTx := NewCoinbaseTX(minerAddr, fmt.Sprintf("Block %d", height))
+		newBlock := bc.MineBlock([]*Transaction{cbTx, tx})
+		UTXOSet.Update(newBlock)
+	} else {
+		bc.AddPending(tx)
+		sendTx(knownNodes[0], tx)
+	}
+	fmt.Printf("%x\n", tx.ID)
+}
+
+// decodePartialTransaction decodes a hex-encoded PartialTransaction.
+func decodePartialTransaction(txHex string) *PartialTransaction {
+	data, err := hex.DecodeString(txHex)
+	if err != nil {
+		log.Panic(err)
+	}
+	ptx, err := DeserializePartialTransaction(data)
+	if err != nil {
+		log.Panic(err)
+	}
+	return ptx
 }
 
 func (cli *CLI) startNode(nodeID, minerAddr string) {
@@ -81,19 +600,48 @@ func (cli *CLI) startNode(nodeID, minerAddr string) {
 }
 
 func (cli *CLI) printUsage() {
-	fmt.Println("Usage:")
+	fmt.Println("Usage: [-network mainnet|testnet|regtest] [-regtest] COMMAND")
 	fmt.Println(" createblockchain -address ADDRESS: Create a blockchain and send genesis block reward to ADDRESS")
-	fmt.Println(" createwallet: Generate a new key pair and saves it to the wallet file")
-	fmt.Println(" listaddresses: List all addresses from the wallet file")
-	fmt.Println(" getbalance -address ADDRESS: Get balance of ADDRESS")
+	fmt.Println(" createwallet -bech32: Generate a new key pair and saves it to the wallet file. -bech32 prints its Bech32 address instead of its Base58 address")
+	fmt.Println(" listaddresses -label LABEL -json: List the addresses of the wallet file, including those only watched, sorted. -label only lists those labeled LABEL. -json adds their metadata and balances, which count the outputs paying to both addresses of a key")
+	fmt.Println(" setlabel -address ADDRESS -label LABEL -note NOTE: Set the label and the note of ADDRESS in the wallet file")
+	fmt.Println(" importaddress -address ADDRESS: Watch ADDRESS without its private key")
+	fmt.Println(" importpubkey -pubkey PUBKEY: Watch the address of PUBKEY without its private key. Watched public keys can be used by createmultisig")
+	fmt.Println(" dumpprivkey -address ADDRESS: Print the private key of ADDRESS in Wallet Import Format")
+	fmt.Println(" importprivkey -privkey WIF: Add the private key WIF to the wallet file and rescan its unspent outputs")
+	fmt.Println(" vanity -prefix PREFIX -ignorecase: Search for a key whose address starts with PREFIX on every core and add it to the wallet file")
+	fmt.Println(" backupwallet -path PATH: Copy the wallet file to PATH")
+	fmt.Println(" importwallet -path PATH -passphrase PASSPHRASE: Add the keys and watched addresses of the wallet file backed up to PATH and rescan their unspent outputs. PASSPHRASE unlocks an encrypted backup")
+	fmt.Println(" restorewallet -mnemonic MNEMONIC: Restore the wallet file from its recovery phrase and find its used addresses")
+	fmt.Println(" encryptwallet -passphrase PASSPHRASE: Encrypt the private keys of the wallet file with PASSPHRASE")
+	fmt.Println(" walletpassphrase -passphrase PASSPHRASE -timeout SECONDS: Unlock the wallet file for SECONDS, keeping the unlocked key in memory while the command runs")
+	fmt.Println(" walletlock: Lock the wallet file before its unlock timeout")
+	fmt.Println(" changepassphrase -old OLD -new NEW: Change the passphrase of the wallet file")
+	fmt.Println(" generate N -address ADDRESS: Mine N blocks sending rewards to ADDRESS (regtest only)")
+	fmt.Println(" getbalance -address ADDRESS -minconf N: Get balance of ADDRESS, counting outputs with fewer than N confirmations and those of unconfirmed transactions as unconfirmed, and coinbase outputs yet to mature as immature. For a key of the wallet file, outputs paying to its Base58 and Bech32 addresses both count")
+	fmt.Println(" getpubkey -address ADDRESS: Print the public key of ADDRESS from the wallet file")
+	fmt.Println(" signmessage -address ADDRESS -message MESSAGE: Sign MESSAGE with the key of ADDRESS, proving its ownership")
+	fmt.Println(" verifymessage -address ADDRESS -signature SIGNATURE -message MESSAGE: Check that SIGNATURE of MESSAGE was made with the key of ADDRESS")
+	fmt.Println(" createmultisig -nrequired M -keys KEY,...: Create a pay-to-script-hash address and a Bech32 address requiring M signatures of the KEYs, given as public keys or addresses of the wallet file")
+	fmt.Println(" spendmultisig -redeemscript SCRIPT -to TO -amount AMOUNT -bech32: Create a transaction sending AMOUNT from the multisig address of SCRIPT to TO, to be signed by its owners. -bech32 spends from its Bech32 address")
+	fmt.Println(" createrawtransaction -inputs TXID:VOUT,... -outputs ADDRESS:AMOUNT,... -locktime LOCKTIME -redeemscripts SCRIPT,...: Create an unsigned transaction spending the given outputs, leaving what they are worth beyond the outputs as fee. SCRIPTs are the redeem scripts of pay-to-script-hash outputs spent")
+	fmt.Println(" decoderawtransaction -tx TX: Print the partially signed TX as JSON")
+	fmt.Println(" signrawtransaction -tx TX -sighash TYPE: Add the signatures the wallet file can make to the partially signed TX. TYPE is ALL, NONE or SINGLE, optionally followed by |ANYONECANPAY")
+	fmt.Println(" combinesignatures -txs TX,...: Merge the signatures of copies of a partially signed transaction")
+	fmt.Println(" sendrawtransaction -tx TX -miner ADDRESS: Broadcast the fully signed TX. -miner mines it immediately on the same node, rewarding ADDRESS")
 	fmt.Println("	printchain: Print all blocks of the blockchain")
 	fmt.Println(" reindexutxo: Rebuilds the UTXO set")
-	fmt.Println(" send -from FROM -to TO -amount AMOUNT: Send AMOUNT of coins from FROM address to TO")
+	fmt.Println(" send -from FROM -to TO -amount AMOUNT -locktime LOCKTIME -coinselect STRATEGY -feerate RATE -exclude TXID:VOUT,... -spend TXID:VOUT,...: Send AMOUNT of coins from FROM address to TO. Without -from, coins of every address of the wallet file are spent and change goes to a new address. -locktime delays mining until a block height or Unix timestamp. STRATEGY picks the coins spent: bnb avoids change when it can, largest spends the largest coins first, random spreads change. RATE is the fee per 1000 bytes. -unsigned prints the transaction for signrawtransaction on the machine holding the key of FROM")
+	fmt.Println(" sendmany -from FROM -to ADDRESS:AMOUNT,... -file FILE: Send to many addresses in one transaction, taking payments from -to or from a CSV or JSON FILE. Takes the other options of send")
+	fmt.Println(" listunspent -address ADDRESS: List the unspent outputs of ADDRESS as TXID:VOUT VALUE")
+	fmt.Println(" listtransactions -address ADDRESS: List the confirmed and unconfirmed transactions paying to or spending from ADDRESS, with the change of its balance, the fee and the counterparties")
+	fmt.Println(" anchor -data HEX -from FROM: Timestamp up to 80 bytes of HEX data, such as a document hash, in a transaction funded by FROM. Without -from, a funded address of the wallet file pays")
+	fmt.Println(" getanchor -data HEX: Find the transaction and block that first anchored HEX data")
 	fmt.Println(" startnode -miner ADDRESS: Start a node with ID specified in NODE_ID env. var. -miner enables mining")
 }
 
-func (cli *CLI) validateArgs() {
-	if len(os.Args) < 2 {
+func (cli *CLI) validateArgs(args []string) {
+	if len(args) < 1 {
 		cli.printUsage()
 		os.Exit(1)
 	}
@@ -121,11 +669,232 @@ func (cli *CLI) printChain(nodeID string) {
 	}
 }
 
-func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow bool) {
-	if !ValidateAddr(from) {
-		log.Panic("error: address is not valid")
+func (cli *CLI) send(from, to string, amount int, lockTime uint32, control *CoinControl, nodeID string, mineNow bool) {
+	if !ValidateAddr(to) {
+		log.Panic("error: address is not valid")
+	}
+	cli.sendMany(from, []Payment{{to, amount}}, lockTime, control, nodeID, mineNow)
+}
+
+// sendMany pays payments from the from address, or from every address of
+// the wallet file if from is empty. Change then goes to a new address.
+func (cli *CLI) sendMany(from string, payments []Payment, lockTime uint32, control *CoinControl, nodeID string, mineNow bool) {
+	if from != "" && !ValidateAddr(from) {
+		log.Panic("error: address is not valid")
+	}
+	bc := NewBlockChain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	wallets, err := NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	if wallets.IsLocked() {
+		log.Panic(errWalletLocked)
+	}
+
+	var tx *Transaction
+	if from != "" {
+		if wallets.IsWatchOnly(from) {
+			log.Panic(errWatchOnly)
+		}
+		wallet := wallets.FindAddress(from)
+		if wallet == nil {
+			log.Panic("error: address is not in the wallet file")
+		}
+		tx = NewSendManyTransaction(wallet, payments, lockTime, control, &UTXOSet)
+	} else {
+		tx = NewWalletTransaction(wallets, payments, lockTime, control, &UTXOSet)
+		if mineNow {
+			from = wallets.CreateWallet()
+		}
+		// Keep the keys of the new change and reward addresses.
+		wallets.SaveToFile(nodeID)
+	}
+
+	if mineNow {
+		cbTx := NewCoinbaseTX(from, "")
+		txs := []*Transaction{cbTx, tx}
+		newBlock := bc.MineBlock(txs)
+		UTXOSet.Update(newBlock)
+	} else {
+		bc.AddPending(tx)
+		sendTx(knownNodes[0], tx)
+	}
+	fmt.Printf("Transaction: %x\n", tx.ID)
+	fmt.Println("Success!")
+}
+
+// createUnsignedTransaction prints a transaction paying payments from the
+// from address, to be signed offline with signrawtransaction.
+func (cli *CLI) createUnsignedTransaction(from string, payments []Payment, lockTime uint32, control *CoinControl, nodeID string) {
+	bc := NewBlockChain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	ptx, err := NewUnsignedTransaction(from, payments, lockTime, control, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("%x\n", ptx.Serialize())
+}
+
+func (cli *CLI) importAddress(address, nodeID string) {
+	wallets, err := NewWallets(nodeID)
+	if err != nil && !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+	err = wallets.ImportAddress(address)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Printf("Watching %s\n", address)
+}
+
+func (cli *CLI) importPubKey(pubKeyHex, nodeID string) {
+	pubKey, err := hex.DecodeString(pubKeyHex)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets, err := NewWallets(nodeID)
+	if err != nil && !os.IsNotExist(err) {
+		log.Panic(err)
+	}
+	address, err := wallets.ImportPubKey(pubKey)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Printf("Watching %s\n", address)
+}
+
+// readPayments reads the payments of sendmany from list, ADDRESS:AMOUNT,...,
+// or from file, JSON if its name ends in .json and CSV otherwise.
+func readPayments(list, file string) []Payment {
+	if list != "" {
+		payments, err := ParsePaymentList(list)
+		if err != nil {
+			log.Panic(err)
+		}
+		return payments
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer f.Close()
+
+	parse := ParsePaymentsCSV
+	if strings.HasSuffix(strings.ToLower(file), ".json") {
+		parse = ParsePaymentsJSON
+	}
+	payments, err := parse(f)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return payments
+}
+
+// newCoinControl builds the coin control of send from its flags. exclude
+// and spend are comma-separated lists of TXID:VOUT outpoints.
+func newCoinControl(strategy string, feeRate int, exclude, spend string) *CoinControl {
+	selector, err := ParseCoinSelector(strategy)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return &CoinControl{
+		Selector: selector,
+		FeeRate:  feeRate,
+		Exclude:  parseOutPoints(exclude),
+		Spend:    parseOutPoints(spend),
+	}
+}
+
+func parseOutPoints(list string) []OutPoint {
+	var outPoints []OutPoint
+	if list == "" {
+		return outPoints
+	}
+
+	for _, s := range strings.Split(list, ",") {
+		outPoint, err := ParseOutPoint(s)
+		if err != nil {
+			log.Panic(err)
+		}
+		outPoints = append(outPoints, outPoint)
+	}
+	return outPoints
+}
+
+func (cli *CLI) listUnspent(address, nodeID string) {
+	lockScript, err := PayToAddrScript(address)
+	if err != nil {
+		log.Panic(err)
+	}
+	bc := NewBlockChain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	for _, coin := range UTXOSet.FindCoins(lockScript) {
+		fmt.Printf("%s %d\n", coin.OutPoint, coin.Output.Value)
+	}
+}
+
+func (cli *CLI) listTransactions(address, nodeID string) {
+	bc := NewBlockChain(nodeID)
+	defer bc.db.Close()
+
+	history, err := bc.ListTransactions(address)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for _, entry := range history {
+		fmt.Printf("Transaction %x:\n", entry.Txid)
+		if entry.Height < 0 {
+			fmt.Println("    Unconfirmed")
+		} else {
+			fmt.Printf("    Height: %d (%d confirmations)\n", entry.Height, entry.Confirmations)
+		}
+		fmt.Printf("    Amount: %+d\n", entry.Amount)
+		fmt.Printf("    Fee: %d\n", entry.Fee)
+		if len(entry.Counterparties) > 0 {
+			fmt.Printf("    Counterparties: %s\n", strings.Join(entry.Counterparties, ", "))
+		}
+	}
+}
+
+// fundingAddress returns the first address of the wallet file, in sorted
+// order, with unspent outputs to fund a transaction.
+func fundingAddress(wallets *Wallets, UTXOSet *UTXOSet) string {
+	addresses := wallets.GetAddrs()
+	sort.Strings(addresses)
+
+	for _, address := range addresses {
+		wallet := wallets.GetWallet(address)
+		for _, coin := range UTXOSet.FindCoins(wallet.LockScripts()...) {
+			if coin.Output.Value >= 1 {
+				return address
+			}
+		}
 	}
-	if !ValidateAddr(to) {
+
+	log.Panic(errInsufficientFunds)
+	return ""
+}
+
+// anchor anchors data in a transaction funded by the from address, or by an
+// address of the wallet file if from is empty.
+func (cli *CLI) anchor(from string, data []byte, nodeID string, mineNow bool) {
+	if from != "" && !ValidateAddr(from) {
 		log.Panic("error: address is not valid")
 	}
 	bc := NewBlockChain(nodeID)
@@ -136,18 +905,67 @@ func (cli *CLI) send(from, to string, amount int, nodeID string, mineNow bool) {
 	if err != nil {
 		log.Panic(err)
 	}
-	wallet := wallets.GetWallet(from)
+	if wallets.IsLocked() {
+		log.Panic(errWalletLocked)
+	}
+	if from == "" {
+		from = fundingAddress(wallets, &UTXOSet)
+	}
+	wallet := wallets.FindAddress(from)
+	if wallet == nil {
+		log.Panic("error: address is not in the wallet file")
+	}
 
-	tx := NewUTXOTransaction(&wallet, to, amount, &UTXOSet)
+	tx := NewDataTransaction(wallet, data, &UTXOSet)
 	if mineNow {
-		cbTx := NewCoinbaseTX(from, "")
-		txs := []*Transaction{cbTx, tx}
-		newBlock := bc.MineBlock(txs)
+		height := bc.GetBestHeight() + 1
+		cbTx := NewCoinbaseTX(from, fmt.Sprintf("Block %d", height))
+		newBlock := bc.MineBlock([]*Transaction{cbTx, tx})
 		UTXOSet.Update(newBlock)
 	} else {
+		bc.AddPending(tx)
 		sendTx(knownNodes[0], tx)
 	}
-	fmt.Println("Success!")
+	fmt.Printf("%x\n", tx.ID)
+}
+
+func (cli *CLI) getAnchor(data []byte, nodeID string) {
+	bc := NewBlockChain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	anchor, err := UTXOSet.FindAnchor(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Transaction: %x\n", anchor.TxID)
+	fmt.Printf("Block: %x\n", anchor.BlockHash)
+	fmt.Printf("Height: %d\n", anchor.Height)
+	fmt.Printf("Time: %s\n", time.Unix(anchor.Timestamp, 0).UTC().Format(time.RFC3339))
+}
+
+func (cli *CLI) generate(n int, address, nodeID string) {
+	if !activeNet.GenerateSupported {
+		log.Panicf("error: generate is not available on %s", activeNet.Name)
+	}
+	if !ValidateAddr(address) {
+		log.Panic("error: address is not valid")
+	}
+	bc := NewBlockChain(nodeID)
+	UTXOSet := UTXOSet{bc}
+	defer bc.db.Close()
+
+	for i := 0; i < n; i++ {
+		// Commit to the height so that coinbases paying the same address
+		// still get distinct IDs.
+		height := bc.GetBestHeight() + 1
+		cbTx := NewCoinbaseTX(address, fmt.Sprintf("Block %d", height))
+		newBlock := bc.MineBlock([]*Transaction{cbTx})
+		UTXOSet.Update(newBlock)
+
+		fmt.Printf("%x\n", newBlock.Hash)
+	}
 }
 
 func (cli *CLI) reindexUTXO(nodeID string) {
@@ -161,7 +979,22 @@ func (cli *CLI) reindexUTXO(nodeID string) {
 
 // Run is an entry point for CLI, it parses command line arguments and process es commands.
 func (cli *CLI) Run() {
-	cli.validateArgs()
+	globalFlags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	network := globalFlags.String("network", MainNetParams.Name, "The network to use: mainnet, testnet or regtest")
+	regtest := globalFlags.Bool("regtest", false, "Use the regression test network, same as -network regtest")
+	err := globalFlags.Parse(os.Args[1:])
+	if err != nil {
+		log.Panic(err)
+	}
+	if *regtest {
+		*network = RegTestParams.Name
+	}
+	err = selectNetwork(*network)
+	if err != nil {
+		log.Panic(err)
+	}
+	args := globalFlags.Args()
+	cli.validateArgs(args)
 
 	nodeID := os.Getenv("NODE_ID")
 	if nodeID == "" {
@@ -173,57 +1006,308 @@ func (cli *CLI) Run() {
 	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
 	listAddrsCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	setLabelCmd := flag.NewFlagSet("setlabel", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	sendManyCmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
+	listUnspentCmd := flag.NewFlagSet("listunspent", flag.ExitOnError)
+	listTransactionsCmd := flag.NewFlagSet("listtransactions", flag.ExitOnError)
+	importAddressCmd := flag.NewFlagSet("importaddress", flag.ExitOnError)
+	dumpPrivKeyCmd := flag.NewFlagSet("dumpprivkey", flag.ExitOnError)
+	importPrivKeyCmd := flag.NewFlagSet("importprivkey", flag.ExitOnError)
+	vanityCmd := flag.NewFlagSet("vanity", flag.ExitOnError)
+	backupWalletCmd := flag.NewFlagSet("backupwallet", flag.ExitOnError)
+	importWalletCmd := flag.NewFlagSet("importwallet", flag.ExitOnError)
+	importPubKeyCmd := flag.NewFlagSet("importpubkey", flag.ExitOnError)
 	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	generateCmd := flag.NewFlagSet("generate", flag.ExitOnError)
+	encryptWalletCmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	walletPassphraseCmd := flag.NewFlagSet("walletpassphrase", flag.ExitOnError)
+	walletLockCmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
+	changePassphraseCmd := flag.NewFlagSet("changepassphrase", flag.ExitOnError)
+	restoreWalletCmd := flag.NewFlagSet("restorewallet", flag.ExitOnError)
+	getPubKeyCmd := flag.NewFlagSet("getpubkey", flag.ExitOnError)
+	signMessageCmd := flag.NewFlagSet("signmessage", flag.ExitOnError)
+	verifyMessageCmd := flag.NewFlagSet("verifymessage", flag.ExitOnError)
+	createMultiSigCmd := flag.NewFlagSet("createmultisig", flag.ExitOnError)
+	spendMultiSigCmd := flag.NewFlagSet("spendmultisig", flag.ExitOnError)
+	createRawTxCmd := flag.NewFlagSet("createrawtransaction", flag.ExitOnError)
+	decodeRawTxCmd := flag.NewFlagSet("decoderawtransaction", flag.ExitOnError)
+	signRawTxCmd := flag.NewFlagSet("signrawtransaction", flag.ExitOnError)
+	combineSigsCmd := flag.NewFlagSet("combinesignatures", flag.ExitOnError)
+	sendRawTxCmd := flag.NewFlagSet("sendrawtransaction", flag.ExitOnError)
+	anchorCmd := flag.NewFlagSet("anchor", flag.ExitOnError)
+	getAnchorCmd := flag.NewFlagSet("getanchor", flag.ExitOnError)
 
 	getBalanceAddr := getBalanceCmd.String("address", "", "The address to get balance for")
+	listAddrsLabel := listAddrsCmd.String("label", "", "Only list the addresses with this label")
+	listAddrsJSON := listAddrsCmd.Bool("json", false, "Print the addresses as JSON, with their metadata and balances")
+	setLabelAddr := setLabelCmd.String("address", "", "The address to label")
+	setLabelLabel := setLabelCmd.String("label", "", "The label of the address")
+	setLabelNote := setLabelCmd.String("note", "", "A note about the address")
+	getBalanceMinConf := getBalanceCmd.Int("minconf", 1, "The confirmations for outputs to count as confirmed")
 	createBlockchainAddr := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
-	sendFrom := sendCmd.String("from", "", "Source wallet address")
+	createWalletBech32 := createWalletCmd.Bool("bech32", false, "Print the Bech32 address of the new key")
+	sendFrom := sendCmd.String("from", "", "Source wallet address, all addresses of the wallet file if empty")
 	sendTo := sendCmd.String("to", "", "Receiver wallet address.")
 	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
 	sendMine := sendCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendUnsigned := sendCmd.Bool("unsigned", false, "Print the transaction unsigned, for signrawtransaction on the machine holding the key of FROM")
+	sendLockTime := sendCmd.Uint("locktime", 0, "Block height, or Unix timestamp from 500000000 up, before which the transaction cannot be mined")
+	sendCoinSelect := sendCmd.String("coinselect", "bnb", "Coin selection strategy: largest, bnb or random")
+	sendFeeRate := sendCmd.Int("feerate", 0, "Fee per 1000 bytes of transaction")
+	sendExclude := sendCmd.String("exclude", "", "Comma-separated TXID:VOUT outputs not to spend")
+	sendSpend := sendCmd.String("spend", "", "Comma-separated TXID:VOUT outputs to spend in any case")
+	sendManyFrom := sendManyCmd.String("from", "", "Source wallet address, all addresses of the wallet file if empty")
+	sendManyTo := sendManyCmd.String("to", "", "Comma-separated ADDRESS:AMOUNT payments")
+	sendManyFile := sendManyCmd.String("file", "", "CSV file of address,amount records, or JSON file of an {\"ADDRESS\": AMOUNT} object if named *.json")
+	sendManyMine := sendManyCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendManyUnsigned := sendManyCmd.Bool("unsigned", false, "Print the transaction unsigned, for signrawtransaction on the machine holding the key of FROM")
+	sendManyLockTime := sendManyCmd.Uint("locktime", 0, "Block height, or Unix timestamp from 500000000 up, before which the transaction cannot be mined")
+	sendManyCoinSelect := sendManyCmd.String("coinselect", "bnb", "Coin selection strategy: largest, bnb or random")
+	sendManyFeeRate := sendManyCmd.Int("feerate", 0, "Fee per 1000 bytes of transaction")
+	sendManyExclude := sendManyCmd.String("exclude", "", "Comma-separated TXID:VOUT outputs not to spend")
+	sendManySpend := sendManyCmd.String("spend", "", "Comma-separated TXID:VOUT outputs to spend in any case")
+	dumpPrivKeyAddr := dumpPrivKeyCmd.String("address", "", "The address whose private key to print")
+	importPrivKey := importPrivKeyCmd.String("privkey", "", "The private key to import, in Wallet Import Format")
+	vanityPrefix := vanityCmd.String("prefix", "", "The prefix of the address to find, starting with the first character of addresses of the network")
+	vanityIgnoreCase := vanityCmd.Bool("ignorecase", false, "Match the prefix regardless of case")
+	backupWalletPath := backupWalletCmd.String("path", "", "The file to back the wallet file up to")
+	importWalletPath := importWalletCmd.String("path", "", "The backed up wallet file to import")
+	importWalletPassphrase := importWalletCmd.String("passphrase", "", "The passphrase of an encrypted backup")
+	importAddress := importAddressCmd.String("address", "", "The address to watch")
+	importPubKey := importPubKeyCmd.String("pubkey", "", "The hex-encoded public key whose address to watch")
+	listUnspentAddr := listUnspentCmd.String("address", "", "The address to list unspent outputs of")
+	listTransactionsAddr := listTransactionsCmd.String("address", "", "The address to list transactions of")
 	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining mode and send reward to ADDRESS")
+	generateAddr := generateCmd.String("address", "", "The address to send block rewards to")
+	generateBlocks := 0
+	encryptWalletPassphrase := encryptWalletCmd.String("passphrase", "", "The passphrase to encrypt the wallet file with")
+	walletPassphrase := walletPassphraseCmd.String("passphrase", "", "The passphrase of the wallet file")
+	walletPassphraseTimeout := walletPassphraseCmd.Int("timeout", 60, "Seconds to keep the wallet file unlocked")
+	changePassphraseOld := changePassphraseCmd.String("old", "", "The current passphrase of the wallet file")
+	changePassphraseNew := changePassphraseCmd.String("new", "", "The new passphrase of the wallet file")
+	restoreWalletMnemonic := restoreWalletCmd.String("mnemonic", "", "The recovery phrase of the wallet file")
+	getPubKeyAddr := getPubKeyCmd.String("address", "", "The address of the wallet file to print the public key of")
+	signMessageAddr := signMessageCmd.String("address", "", "The address of the wallet file whose key signs")
+	signMessageMessage := signMessageCmd.String("message", "", "The message to sign")
+	verifyMessageAddr := verifyMessageCmd.String("address", "", "The address the message was signed for")
+	verifyMessageSignature := verifyMessageCmd.String("signature", "", "The Base64 signature printed by signmessage")
+	verifyMessageMessage := verifyMessageCmd.String("message", "", "The message signed")
+	createMultiSigRequired := createMultiSigCmd.Int("nrequired", 0, "The number of signatures required")
+	createMultiSigKeys := createMultiSigCmd.String("keys", "", "Comma-separated public keys or addresses of the wallet file")
+	spendMultiSigScript := spendMultiSigCmd.String("redeemscript", "", "The redeem script of the multisig address")
+	spendMultiSigTo := spendMultiSigCmd.String("to", "", "Receiver wallet address")
+	spendMultiSigAmount := spendMultiSigCmd.Int("amount", 0, "Amount to send")
+	spendMultiSigBech32 := spendMultiSigCmd.Bool("bech32", false, "Spend from the Bech32 address of the redeem script")
+	createRawTxInputs := createRawTxCmd.String("inputs", "", "Comma-separated TXID:VOUT outputs to spend")
+	createRawTxOutputs := createRawTxCmd.String("outputs", "", "Comma-separated ADDRESS:AMOUNT outputs to create")
+	createRawTxLockTime := createRawTxCmd.Uint("locktime", 0, "Block height, or Unix timestamp from 500000000 up, before which the transaction cannot be mined")
+	createRawTxRedeemScripts := createRawTxCmd.String("redeemscripts", "", "Comma-separated hex-encoded redeem scripts of the pay-to-script-hash outputs spent")
+	decodeRawTx := decodeRawTxCmd.String("tx", "", "The hex-encoded partially signed transaction")
+	signRawTx := signRawTxCmd.String("tx", "", "The hex-encoded partially signed transaction")
+	signRawTxSigHash := signRawTxCmd.String("sighash", SigHashAll.String(), "The parts of the transaction signatures commit to: ALL, NONE or SINGLE, optionally followed by |ANYONECANPAY")
+	combineSigsTxs := combineSigsCmd.String("txs", "", "Comma-separated hex-encoded copies of a partially signed transaction")
+	sendRawTx := sendRawTxCmd.String("tx", "", "The hex-encoded signed transaction")
+	sendRawTxMiner := sendRawTxCmd.String("miner", "", "Mine immediately on the same node and send the reward to ADDRESS")
+	anchorFrom := anchorCmd.String("from", "", "Wallet address paying for the transaction, any funded one by default")
+	anchorData := anchorCmd.String("data", "", "Hex-encoded data to anchor")
+	anchorMine := anchorCmd.Bool("mine", false, "Mine immediately on the same node")
+	getAnchorData := getAnchorCmd.String("data", "", "Hex-encoded anchored data")
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "getbalance":
-		err := getBalanceCmd.Parse(os.Args[2:])
+		err := getBalanceCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "createblockchain":
-		err := createBlockchainCmd.Parse(os.Args[2:])
+		err := createBlockchainCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "createwallet":
-		err := createWalletCmd.Parse(os.Args[2:])
+		err := createWalletCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "listaddresses":
-		err := listAddrsCmd.Parse(os.Args[2:])
+		err := listAddrsCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "setlabel":
+		err := setLabelCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "printchain":
-		err := printChainCmd.Parse(os.Args[2:])
+		err := printChainCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "reindexutxo":
-		err := reindexUTXOCmd.Parse(os.Args[2:])
+		err := reindexUTXOCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "send":
-		err := sendCmd.Parse(os.Args[2:])
+		err := sendCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendmany":
+		err := sendManyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "dumpprivkey":
+		err := dumpPrivKeyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importprivkey":
+		err := importPrivKeyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "vanity":
+		err := vanityCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "backupwallet":
+		err := backupWalletCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importwallet":
+		err := importWalletCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importaddress":
+		err := importAddressCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importpubkey":
+		err := importPubKeyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listunspent":
+		err := listUnspentCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listtransactions":
+		err := listTransactionsCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "startnode":
-		err := startNodeCmd.Parse(os.Args[2:])
+		err := startNodeCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "encryptwallet":
+		err := encryptWalletCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletpassphrase":
+		err := walletPassphraseCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletlock":
+		err := walletLockCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "changepassphrase":
+		err := changePassphraseCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "restorewallet":
+		err := restoreWalletCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getpubkey":
+		err := getPubKeyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signmessage":
+		err := signMessageCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "verifymessage":
+		err := verifyMessageCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createmultisig":
+		err := createMultiSigCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "spendmultisig":
+		err := spendMultiSigCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createrawtransaction":
+		err := createRawTxCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "decoderawtransaction":
+		err := decodeRawTxCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signrawtransaction":
+		err := signRawTxCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "combinesignatures":
+		err := combineSigsCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendrawtransaction":
+		err := sendRawTxCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "anchor":
+		err := anchorCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getanchor":
+		err := getAnchorCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "generate":
+		// The block count comes first, ahead of the flags.
+		cmdArgs := args[1:]
+		if len(cmdArgs) > 0 && !strings.HasPrefix(cmdArgs[0], "-") {
+			generateBlocks, err = strconv.Atoi(cmdArgs[0])
+			if err != nil {
+				log.Panic(err)
+			}
+			cmdArgs = cmdArgs[1:]
+		}
+		err = generateCmd.Parse(cmdArgs)
 		if err != nil {
 			log.Panic(err)
 		}
@@ -237,7 +1321,7 @@ func (cli *CLI) Run() {
 			getBalanceCmd.Usage()
 			os.Exit(1)
 		}
-		cli.getBalance(*getBalanceAddr, nodeID)
+		cli.getBalance(*getBalanceAddr, *getBalanceMinConf, nodeID)
 	}
 
 	if createBlockchainCmd.Parsed() {
@@ -249,11 +1333,55 @@ func (cli *CLI) Run() {
 	}
 
 	if createWalletCmd.Parsed() {
-		cli.createWallet(nodeID)
+		cli.createWallet(*createWalletBech32, nodeID)
+	}
+
+	if encryptWalletCmd.Parsed() {
+		if *encryptWalletPassphrase == "" {
+			encryptWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.encryptWallet(*encryptWalletPassphrase, nodeID)
+	}
+
+	if walletPassphraseCmd.Parsed() {
+		if *walletPassphrase == "" || *walletPassphraseTimeout <= 0 {
+			walletPassphraseCmd.Usage()
+			os.Exit(1)
+		}
+		cli.walletPassphrase(*walletPassphrase, *walletPassphraseTimeout, nodeID)
+	}
+
+	if walletLockCmd.Parsed() {
+		cli.walletLock(nodeID)
+	}
+
+	if changePassphraseCmd.Parsed() {
+		if *changePassphraseOld == "" || *changePassphraseNew == "" {
+			changePassphraseCmd.Usage()
+			os.Exit(1)
+		}
+		cli.changePassphrase(*changePassphraseOld, *changePassphraseNew, nodeID)
+	}
+
+	if restoreWalletCmd.Parsed() {
+		if *restoreWalletMnemonic == "" {
+			restoreWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.restoreWallet(*restoreWalletMnemonic, nodeID)
 	}
 
 	if listAddrsCmd.Parsed() {
-		cli.listAllAddrs(nodeID)
+		cli.listAllAddrs(*listAddrsLabel, *listAddrsJSON, nodeID)
+	}
+
+	if setLabelCmd.Parsed() {
+		if *setLabelAddr == "" {
+			setLabelCmd.Usage()
+			os.Exit(1)
+		}
+		cli.setLabel(*setLabelAddr, *setLabelLabel, *setLabelNote, nodeID)
 	}
 
 	if reindexUTXOCmd.Parsed() {
@@ -265,11 +1393,222 @@ func (cli *CLI) Run() {
 	}
 
 	if sendCmd.Parsed() {
-		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
+		if *sendTo == "" || (*sendUnsigned && *sendFrom == "") || *sendAmount <= 0 || *sendLockTime > math.MaxUint32 || *sendFeeRate < 0 {
 			sendCmd.Usage()
 			os.Exit(1)
 		}
-		cli.send(*sendFrom, *sendTo, *sendAmount, nodeID, *sendMine)
+		control := newCoinControl(*sendCoinSelect, *sendFeeRate, *sendExclude, *sendSpend)
+		if *sendUnsigned {
+			cli.createUnsignedTransaction(*sendFrom, []Payment{{*sendTo, *sendAmount}}, uint32(*sendLockTime), control, nodeID)
+		} else {
+			cli.send(*sendFrom, *sendTo, *sendAmount, uint32(*sendLockTime), control, nodeID, *sendMine)
+		}
+	}
+
+	if sendManyCmd.Parsed() {
+		if (*sendManyTo == "") == (*sendManyFile == "") || (*sendManyUnsigned && *sendManyFrom == "") || *sendManyLockTime > math.MaxUint32 || *sendManyFeeRate < 0 {
+			sendManyCmd.Usage()
+			os.Exit(1)
+		}
+		payments := readPayments(*sendManyTo, *sendManyFile)
+		control := newCoinControl(*sendManyCoinSelect, *sendManyFeeRate, *sendManyExclude, *sendManySpend)
+		if *sendManyUnsigned {
+			cli.createUnsignedTransaction(*sendManyFrom, payments, uint32(*sendManyLockTime), control, nodeID)
+		} else {
+			cli.sendMany(*sendManyFrom, payments, uint32(*sendManyLockTime), control, nodeID, *sendManyMine)
+		}
+	}
+
+	if dumpPrivKeyCmd.Parsed() {
+		if *dumpPrivKeyAddr == "" {
+			dumpPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.dumpPrivKey(*dumpPrivKeyAddr, nodeID)
+	}
+
+	if importPrivKeyCmd.Parsed() {
+		if *importPrivKey == "" {
+			importPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importPrivKey(*importPrivKey, nodeID)
+	}
+
+	if vanityCmd.Parsed() {
+		if *vanityPrefix == "" {
+			vanityCmd.Usage()
+			os.Exit(1)
+		}
+		cli.vanity(*vanityPrefix, *vanityIgnoreCase, nodeID)
+	}
+
+	if backupWalletCmd.Parsed() {
+		if *backupWalletPath == "" {
+			backupWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.backupWallet(*backupWalletPath, nodeID)
+	}
+
+	if importWalletCmd.Parsed() {
+		if *importWalletPath == "" {
+			importWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importWallet(*importWalletPath, *importWalletPassphrase, nodeID)
+	}
+
+	if importAddressCmd.Parsed() {
+		if *importAddress == "" {
+			importAddressCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importAddress(*importAddress, nodeID)
+	}
+
+	if importPubKeyCmd.Parsed() {
+		if *importPubKey == "" {
+			importPubKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importPubKey(*importPubKey, nodeID)
+	}
+
+	if listUnspentCmd.Parsed() {
+		if *listUnspentAddr == "" {
+			listUnspentCmd.Usage()
+			os.Exit(1)
+		}
+		cli.listUnspent(*listUnspentAddr, nodeID)
+	}
+
+	if listTransactionsCmd.Parsed() {
+		if *listTransactionsAddr == "" {
+			listTransactionsCmd.Usage()
+			os.Exit(1)
+		}
+		cli.listTransactions(*listTransactionsAddr, nodeID)
+	}
+
+	if getPubKeyCmd.Parsed() {
+		if *getPubKeyAddr == "" {
+			getPubKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getPubKey(*getPubKeyAddr, nodeID)
+	}
+
+	if signMessageCmd.Parsed() {
+		if *signMessageAddr == "" {
+			signMessageCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signMessage(*signMessageAddr, *signMessageMessage, nodeID)
+	}
+
+	if verifyMessageCmd.Parsed() {
+		if *verifyMessageAddr == "" || *verifyMessageSignature == "" {
+			verifyMessageCmd.Usage()
+			os.Exit(1)
+		}
+		cli.verifyMessage(*verifyMessageAddr, *verifyMessageSignature, *verifyMessageMessage)
+	}
+
+	if createMultiSigCmd.Parsed() {
+		if *createMultiSigRequired <= 0 || *createMultiSigKeys == "" {
+			createMultiSigCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createMultiSig(*createMultiSigRequired, strings.Split(*createMultiSigKeys, ","), nodeID)
+	}
+
+	if spendMultiSigCmd.Parsed() {
+		if *spendMultiSigScript == "" || *spendMultiSigTo == "" || *spendMultiSigAmount <= 0 {
+			spendMultiSigCmd.Usage()
+			os.Exit(1)
+		}
+		cli.spendMultiSig(*spendMultiSigScript, *spendMultiSigTo, *spendMultiSigAmount, *spendMultiSigBech32, nodeID)
+	}
+
+	if createRawTxCmd.Parsed() {
+		if *createRawTxInputs == "" || *createRawTxOutputs == "" || *createRawTxLockTime > math.MaxUint32 {
+			createRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		payments, err := ParsePaymentList(*createRawTxOutputs)
+		if err != nil {
+			log.Panic(err)
+		}
+		var redeemScripts [][]byte
+		if *createRawTxRedeemScripts != "" {
+			for _, scriptHex := range strings.Split(*createRawTxRedeemScripts, ",") {
+				redeemScript, err := hex.DecodeString(scriptHex)
+				if err != nil {
+					log.Panic(err)
+				}
+				redeemScripts = append(redeemScripts, redeemScript)
+			}
+		}
+		cli.createRawTransaction(parseOutPoints(*createRawTxInputs), payments, uint32(*createRawTxLockTime), redeemScripts, nodeID)
+	}
+
+	if decodeRawTxCmd.Parsed() {
+		if *decodeRawTx == "" {
+			decodeRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.decodeRawTransaction(*decodeRawTx)
+	}
+
+	if signRawTxCmd.Parsed() {
+		if *signRawTx == "" {
+			signRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signRawTransaction(*signRawTx, *signRawTxSigHash, nodeID)
+	}
+
+	if combineSigsCmd.Parsed() {
+		if *combineSigsTxs == "" {
+			combineSigsCmd.Usage()
+			os.Exit(1)
+		}
+		cli.combineSignatures(strings.Split(*combineSigsTxs, ","))
+	}
+
+	if sendRawTxCmd.Parsed() {
+		if *sendRawTx == "" {
+			sendRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.sendRawTransaction(*sendRawTx, *sendRawTxMiner, nodeID)
+	}
+
+	if anchorCmd.Parsed() {
+		data, err := hex.DecodeString(*anchorData)
+		if len(data) == 0 || err != nil {
+			anchorCmd.Usage()
+			os.Exit(1)
+		}
+		cli.anchor(*anchorFrom, data, nodeID, *anchorMine)
+	}
+
+	if getAnchorCmd.Parsed() {
+		data, err := hex.DecodeString(*getAnchorData)
+		if len(data) == 0 || err != nil {
+			getAnchorCmd.Usage()
+			os.Exit(1)
+		}
+		cli.getAnchor(data, nodeID)
+	}
+
+	if generateCmd.Parsed() {
+		if *generateAddr == "" || generateBlocks <= 0 {
+			generateCmd.Usage()
+			os.Exit(1)
+		}
+		cli.generate(generateBlocks, *generateAddr, nodeID)
 	}
 
 	if startNodeCmd.Parsed() {
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encoded sizes used to estimate the fee of a transaction before it is
+// built and signed. See transaction_encoding.go.
+const (
+	// p2pkhInputSize is the size of an input spending a pay-to-pubkey-hash
+	// output: a 32-byte Txid and its length, Vout, a signature script
+	// pushing a signature and a public key, and Sequence.
+	p2pkhInputSize = 1 + 32 + 4 + 1 + (1 + signatureLen + 1) + (1 + pubKeyLen) + 4
+	// p2pkhOutputSize is the size of an output paying to a public key hash.
+	p2pkhOutputSize = 8 + 1 + 25
+	// txOverheadSize is the size of the input and output counts and of
+	// LockTime.
+	txOverheadSize = 1 + 1 + 4
+
+	// bnbMaxTries bounds the search of BranchAndBound.
+	bnbMaxTries = 100000
+)
+
+var errInsufficientFunds = errors.New("error: not enough funds")
+
+// OutPoint references an output of a transaction.
+type OutPoint struct {
+	Txid []byte
+	Vout int
+}
+
+// String returns the outpoint as TXID:VOUT.
+func (o OutPoint) String() string {
+	return fmt.Sprintf("%x:%d", o.Txid, o.Vout)
+}
+
+// ParseOutPoint parses an outpoint written as TXID:VOUT.
+func ParseOutPoint(s string) (OutPoint, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return OutPoint{}, fmt.Errorf("outpoint %q is not TXID:VOUT", s)
+	}
+	txid, err := hex.DecodeString(parts[0])
+	if err != nil || len(txid) == 0 {
+		return OutPoint{}, fmt.Errorf("outpoint %q has an invalid transaction ID", s)
+	}
+	vout, err := strconv.Atoi(parts[1])
+	if err != nil || vout < 0 {
+		return OutPoint{}, fmt.Errorf("outpoint %q has an invalid output index", s)
+	}
+
+	return OutPoint{txid, vout}, nil
+}
+
+// Coin is an unspent output along with its outpoint.
+type Coin struct {
+	OutPoint
+	Output TXOutput
+}
+
+// FeePolicy computes the fee of a transaction spending pay-to-pubkey-hash
+// outputs from the number of its inputs.
+type FeePolicy struct {
+	// Rate is the fee per 1000 bytes of encoded transaction.
+	Rate int
+	// BaseSize is the size of the transaction without its inputs and
+	// change output.
+	BaseSize int
+}
+
+// feeFor returns the fee of size bytes, rounded up.
+func (p FeePolicy) feeFor(size int) int {
+	return (p.Rate*size + 999) / 1000
+}
+
+// Fee returns the fee of a transaction with the given number of inputs,
+// and a change output if change is set. Each part is rounded up on its own,
+// so the fee of an input does not depend on the others.
+func (p FeePolicy) Fee(inputs int, change bool) int {
+	fee := p.feeFor(p.BaseSize) + inputs*p.feeFor(p2pkhInputSize)
+	if change {
+		fee += p.feeFor(p2pkhOutputSize)
+	}
+	return fee
+}
+
+// Selection is a set of coins chosen to pay an amount.
+type Selection struct {
+	Coins []Coin
+	// Fee is the amount left to miners.
+	Fee int
+	// Change is the amount sent back to the wallet, or 0 for no change
+	// output.
+	Change int
+}
+
+// Value returns the total value of the selected coins.
+func (s Selection) Value() int {
+	return coinsValue(s.Coins)
+}
+
+func coinsValue(coins []Coin) int {
+	value := 0
+	for _, coin := range coins {
+		value += coin.Output.Value
+	}
+	return value
+}
+
+// newSelection computes the fee and change of spending coins to pay target.
+// A change output is only added if it pays for itself, otherwise the excess
+// goes to the fee. It reports false if coins do not cover target and the fee.
+func newSelection(coins []Coin, target int, fees FeePolicy) (Selection, bool) {
+	value := coinsValue(coins)
+
+	if change := value - target - fees.Fee(len(coins), true); change > 0 {
+		return Selection{Coins: coins, Fee: fees.Fee(len(coins), true), Change: change}, true
+	}
+	if value-target < fees.Fee(len(coins), false) {
+		return Selection{}, false
+	}
+	return Selection{Coins: coins, Fee: value - target}, true
+}
+
+// CoinSelector chooses the coins a transaction spends.
+type CoinSelector interface {
+	// SelectCoins picks coins worth target plus the fee of spending them.
+	SelectCoins(coins []Coin, target int, fees FeePolicy) (Selection, error)
+}
+
+// coinSelectors maps the names of the strategies to their selector.
+var coinSelectors = map[string]CoinSelector{
+	"largest": LargestFirst{},
+	"bnb":     BranchAndBound{},
+	"random":  RandomImprove{},
+}
+
+// ParseCoinSelector returns the selector named name: largest, bnb or random.
+func ParseCoinSelector(name string) (CoinSelector, error) {
+	selector, ok := coinSelectors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown coin selection strategy %q", name)
+	}
+	return selector, nil
+}
+
+// sortByValue returns a copy of coins, largest first.
+func sortByValue(coins []Coin) []Coin {
+	sorted := append([]Coin(nil), coins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Output.Value > sorted[j].Output.Value
+	})
+	return sorted
+}
+
+// LargestFirst spends the largest coins first. It needs few inputs but
+// usually creates change.
+type LargestFirst struct{}
+
+// SelectCoins implements CoinSelector.
+func (LargestFirst) SelectCoins(coins []Coin, target int, fees FeePolicy) (Selection, error) {
+	sorted := sortByValue(coins)
+
+	for n := 0; n <= len(sorted); n++ {
+		if selection, ok := newSelection(sorted[:n], target, fees); ok {
+			return selection, nil
+		}
+	}
+	return Selection{}, errInsufficientFunds
+}
+
+// BranchAndBound searches for coins paying target and the fee without
+// change, wasting at most the cost of a change output. It falls back to
+// LargestFirst when there is no such match.
+type BranchAndBound struct{}
+
+// SelectCoins implements CoinSelector.
+func (BranchAndBound) SelectCoins(coins []Coin, target int, fees FeePolicy) (Selection, error) {
+	// Coins are compared by their value net of the fee of spending them,
+	// so the fee of a set of coins is its base fee only.
+	inputFee := fees.feeFor(p2pkhInputSize)
+	var candidates []Coin
+	for _, coin := range sortByValue(coins) {
+		if coin.Output.Value > inputFee {
+			candidates = append(candidates, coin)
+		}
+	}
+
+	low := target + fees.Fee(0, false)
+	high := target + fees.Fee(0, true)
+
+	// remaining[i] is the net value of candidates from i on.
+	remaining := make([]int, len(candidates)+1)
+	for i := len(candidates) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + candidates[i].Output.Value - inputFee
+	}
+
+	var (
+		best      []Coin
+		found     bool
+		bestValue = high + 1
+		tries     = 0
+		picked    []Coin
+	)
+	var search func(i, value int)
+	search = func(i, value int) {
+		tries++
+		if tries > bnbMaxTries || value > high || value+remaining[i] < low || bestValue == low {
+			return
+		}
+		if value >= low {
+			if value < bestValue {
+				best, found, bestValue = append([]Coin(nil), picked...), true, value
+			}
+			return
+		}
+		if i == len(candidates) {
+			return
+		}
+
+		picked = append(picked, candidates[i])
+		search(i+1, value+candidates[i].Output.Value-inputFee)
+		picked = picked[:len(picked)-1]
+		search(i+1, value)
+	}
+	search(0, 0)
+
+	if found {
+		if selection, ok := newSelection(best, target, fees); ok {
+			return selection, nil
+		}
+	}
+	return LargestFirst{}.SelectCoins(coins, target, fees)
+}
+
+// RandomImprove picks random coins until they cover target, then keeps
+// adding random coins that bring the change closer to target, up to twice
+// target, so that change outputs resemble payments and are useful later.
+type RandomImprove struct {
+	// Rand is the source of randomness, the global source if nil.
+	Rand *rand.Rand
+}
+
+// SelectCoins implements CoinSelector.
+func (r RandomImprove) SelectCoins(coins []Coin, target int, fees FeePolicy) (Selection, error) {
+	order := rand.Perm(len(coins))
+	if r.Rand != nil {
+		order = r.Rand.Perm(len(coins))
+	}
+
+	var picked []Coin
+	selection, ok := newSelection(picked, target, fees)
+	for len(picked) < len(order) && !ok {
+		picked = append(picked, coins[order[len(picked)]])
+		selection, ok = newSelection(picked, target, fees)
+	}
+	if !ok {
+		return Selection{}, errInsufficientFunds
+	}
+
+	distance := func(s Selection) int {
+		if s.Change > target {
+			return s.Change - target
+		}
+		return target - s.Change
+	}
+	for _, i := range order[len(picked):] {
+		improved, ok := newSelection(append(selection.Coins[:len(selection.Coins):len(selection.Coins)], coins[i]), target, fees)
+		if ok && improved.Change <= 2*target && distance(improved) < distance(selection) {
+			selection = improved
+		}
+	}
+
+	return selection, nil
+}
+
+// CoinControl steers the coins a transaction spends.
+type CoinControl struct {
+	// Selector picks the coins, BranchAndBound if nil.
+	Selector CoinSelector
+	// FeeRate is the fee per 1000 bytes of encoded transaction.
+	FeeRate int
+	// Exclude lists outpoints never to spend.
+	Exclude []OutPoint
+	// Spend lists outpoints to spend in any case. Other coins are only
+	// added if they do not cover the amount.
+	Spend []OutPoint
+}
+
+// SelectCoins picks among coins those paying amount and the fee of a
+// transaction of baseSize bytes without inputs and change.
+func (c *CoinControl) SelectCoins(coins []Coin, amount, baseSize int) (Selection, error) {
+	selector := c.Selector
+	if selector == nil {
+		selector = BranchAndBound{}
+	}
+	fees := FeePolicy{Rate: c.FeeRate, BaseSize: baseSize}
+
+	excluded := make(map[string]bool)
+	for _, outPoint := range c.Exclude {
+		excluded[outPoint.String()] = true
+	}
+	spent := make(map[string]bool)
+	for _, outPoint := range c.Spend {
+		spent[outPoint.String()] = true
+	}
+
+	var required, optional []Coin
+	for _, coin := range coins {
+		switch key := coin.OutPoint.String(); {
+		case spent[key] && !excluded[key]:
+			required = append(required, coin)
+			delete(spent, key)
+		case !spent[key] && !excluded[key]:
+			optional = append(optional, coin)
+		}
+	}
+	for _, outPoint := range c.Spend {
+		if spent[outPoint.String()] {
+			return Selection{}, fmt.Errorf("outpoint %s is not a spendable output of the wallet", outPoint)
+		}
+	}
+
+	// The required coins pay for their own inputs, the selector covers
+	// the rest.
+	requiredFee := len(required) * fees.feeFor(p2pkhInputSize)
+	selection, err := selector.SelectCoins(optional, amount+requiredFee-coinsValue(required), fees)
+	if err != nil {
+		return Selection{}, err
+	}
+
+	selection.Coins = append(required, selection.Coins...)
+	selection.Fee += requiredFee
+	return selection, nil
+}
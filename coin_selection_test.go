@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testCoins(values ...int) []Coin {
+	var coins []Coin
+	for i, value := range values {
+		coins = append(coins, Coin{OutPoint{[]byte{byte(i + 1)}, 0}, TXOutput{Value: value}})
+	}
+	return coins
+}
+
+func selectedValues(selection Selection) []int {
+	var values []int
+	for _, coin := range selection.Coins {
+		values = append(values, coin.Output.Value)
+	}
+	return values
+}
+
+func TestLargestFirst(t *testing.T) {
+	coins := testCoins(3, 10, 5, 1)
+
+	selection, err := LargestFirst{}.SelectCoins(coins, 12, FeePolicy{})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{10, 5}, selectedValues(selection))
+	assert.Equal(t, 3, selection.Change)
+	assert.Equal(t, 0, selection.Fee)
+
+	_, err = LargestFirst{}.SelectCoins(coins, 20, FeePolicy{})
+	assert.Equal(t, errInsufficientFunds, err)
+
+	// Fees of 1 per input, 1 per change output and 1 for the rest.
+	fees := FeePolicy{Rate: 7, BaseSize: p2pkhOutputSize}
+	assert.Equal(t, 3, fees.Fee(1, true))
+	selection, err = LargestFirst{}.SelectCoins(coins, 12, fees)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{10, 5}, selectedValues(selection))
+	assert.Equal(t, 0, selection.Change, "The excess would not pay for a change output.")
+	assert.Equal(t, 3, selection.Fee)
+}
+
+func TestBranchAndBound(t *testing.T) {
+	coins := testCoins(10, 7, 4, 3, 1)
+
+	selection, err := BranchAndBound{}.SelectCoins(coins, 8, FeePolicy{})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{7, 1}, selectedValues(selection), "Exact match without change.")
+	assert.Equal(t, 0, selection.Change)
+
+	selection, err = BranchAndBound{}.SelectCoins(coins, 14, FeePolicy{})
+	assert.Nil(t, err)
+	assert.Equal(t, 14, selection.Value())
+
+	// With fees, the match covers the fee of its inputs.
+	fees := FeePolicy{Rate: 7, BaseSize: p2pkhOutputSize}
+	selection, err = BranchAndBound{}.SelectCoins(coins, 8, fees)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, selection.Change)
+	assert.Equal(t, selection.Value()-8, selection.Fee)
+	assert.True(t, selection.Fee >= fees.Fee(len(selection.Coins), false))
+	assert.True(t, selection.Fee <= fees.Fee(len(selection.Coins), true))
+
+	// Without an exact match, it falls back to the largest coins.
+	selection, err = BranchAndBound{}.SelectCoins(testCoins(10, 6), 5, FeePolicy{})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{10}, selectedValues(selection))
+	assert.Equal(t, 5, selection.Change)
+
+	_, err = BranchAndBound{}.SelectCoins(coins, 26, FeePolicy{})
+	assert.Equal(t, errInsufficientFunds, err)
+}
+
+func TestRandomImprove(t *testing.T) {
+	var coins []Coin
+	for i := 0; i < 50; i++ {
+		coins = append(coins, testCoins(1+i%7)...)
+	}
+
+	for seed := int64(0); seed < 20; seed++ {
+		selection, err := RandomImprove{rand.New(rand.NewSource(seed))}.SelectCoins(coins, 10, FeePolicy{})
+		assert.Nil(t, err)
+		assert.Equal(t, selection.Value(), 10+selection.Change)
+		assert.True(t, selection.Change <= 20, "Change is at most twice the amount.")
+	}
+
+	_, err := RandomImprove{}.SelectCoins(testCoins(1, 2), 4, FeePolicy{})
+	assert.Equal(t, errInsufficientFunds, err)
+}
+
+func TestCoinControl(t *testing.T) {
+	coins := testCoins(10, 7, 4)
+
+	control := &CoinControl{Selector: LargestFirst{}, Exclude: []OutPoint{coins[0].OutPoint}}
+	selection, err := control.SelectCoins(coins, 8, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{7, 4}, selectedValues(selection))
+
+	control = &CoinControl{Selector: LargestFirst{}, Spend: []OutPoint{coins[2].OutPoint}}
+	selection, err = control.SelectCoins(coins, 2, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{4}, selectedValues(selection))
+	selection, err = control.SelectCoins(coins, 12, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{4, 10}, selectedValues(selection))
+
+	fees := FeePolicy{Rate: 7}
+	control.FeeRate = fees.Rate
+	selection, err = control.SelectCoins(coins, 12, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, fees.Fee(2, false), selection.Fee)
+	assert.Equal(t, selection.Value(), 12+selection.Fee+selection.Change)
+
+	control = &CoinControl{Spend: []OutPoint{{[]byte{0x09}, 0}}}
+	_, err = control.SelectCoins(coins, 1, 0)
+	assert.NotNil(t, err, "Unknown outpoints cannot be spent.")
+
+	control = &CoinControl{Exclude: []OutPoint{coins[0].OutPoint}}
+	_, err = control.SelectCoins(coins, 12, 0)
+	assert.Equal(t, errInsufficientFunds, err)
+}
+
+func TestParseOutPoint(t *testing.T) {
+	outPoint, err := ParseOutPoint("0a0b:2")
+	assert.Nil(t, err)
+	assert.Equal(t, OutPoint{[]byte{0x0a, 0x0b}, 2}, outPoint)
+	assert.Equal(t, "0a0b:2", outPoint.String())
+
+	for _, s := range []string{"", "0a0b", "0a0b:", ":1", "zz:1", "0a0b:-1", "0a:1:2"} {
+		_, err := ParseOutPoint(s)
+		assert.NotNil(t, err, "Outpoint %q.", s)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	dcrecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+const (
+	// privKeyLen is the length of a serialized private key.
+	privKeyLen = 32
+	// pubKeyLen is the length of a compressed SEC1 public key.
+	pubKeyLen = 33
+	// signatureLen is the length of a signature: r and s, both padded to 32
+	// bytes.
+	signatureLen = 64
+)
+
+var errInvalidPubKey = errors.New("invalid public key")
+
+// Curve is an ECDSA signature scheme over an elliptic curve.
+// Public keys are serialized in compressed SEC1 form and signatures as the
+// fixed-width concatenation of r and s, so neither depends on the length of
+// the numbers they hold.
+type Curve interface {
+	// Name identifies the curve in wallet files.
+	Name() string
+	// Elliptic returns the curve used by ecdsa keys.
+	Elliptic() elliptic.Curve
+	GenerateKey() (ecdsa.PrivateKey, error)
+	// PrivateKeyFromBytes rebuilds a private key from its secret scalar.
+	PrivateKeyFromBytes(d []byte) ecdsa.PrivateKey
+	SerializePubKey(pub *ecdsa.PublicKey) []byte
+	ParsePubKey(pubKey []byte) (*ecdsa.PublicKey, error)
+	Sign(priv *ecdsa.PrivateKey, hash []byte) ([]byte, error)
+	Verify(pubKey, hash, signature []byte) bool
+}
+
+// Secp256k1 is the curve used by Bitcoin. Signatures use deterministic
+// nonces as described in RFC 6979.
+var Secp256k1 Curve = secp256k1Curve{}
+
+// P256 is the NIST P-256 curve.
+var P256 Curve = p256Curve{}
+
+// curveByName returns the curve with the given name. Wallet files written
+// before curves were named hold P-256 keys.
+func curveByName(name string) (Curve, error) {
+	switch name {
+	case Secp256k1.Name():
+		return Secp256k1, nil
+	case P256.Name(), "":
+		return P256, nil
+	}
+	return nil, fmt.Errorf("unknown curve %q", name)
+}
+
+// curveOf returns the curve of ecdsa keys, or nil for curves other than
+// Secp256k1 and P256.
+func curveOf(curve elliptic.Curve) Curve {
+	switch curve {
+	case Secp256k1.Elliptic():
+		return Secp256k1
+	case P256.Elliptic():
+		return P256
+	}
+	return nil
+}
+
+// signingCurve returns the curve of the active network, which signatures are
+// verified with, or an error if privKey is on another curve.
+func signingCurve(privKey *ecdsa.PrivateKey) (Curve, error) {
+	if curveOf(privKey.Curve) != activeNet.Curve {
+		return nil, fmt.Errorf("key is not on the %s curve of %s", activeNet.Curve.Name(), activeNet.Name)
+	}
+	return activeNet.Curve, nil
+}
+
+type secp256k1Curve struct{}
+
+func (secp256k1Curve) Name() string {
+	return "secp256k1"
+}
+
+func (secp256k1Curve) Elliptic() elliptic.Curve {
+	return secp256k1.S256()
+}
+
+func (secp256k1Curve) GenerateKey() (ecdsa.PrivateKey, error) {
+	private, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return ecdsa.PrivateKey{}, err
+	}
+	return *private.ToECDSA(), nil
+}
+
+func (secp256k1Curve) PrivateKeyFromBytes(d []byte) ecdsa.PrivateKey {
+	return *secp256k1.PrivKeyFromBytes(d).ToECDSA()
+}
+
+func (secp256k1Curve) SerializePubKey(pub *ecdsa.PublicKey) []byte {
+	var x, y secp256k1.FieldVal
+	x.SetByteSlice(pub.X.Bytes())
+	y.SetByteSlice(pub.Y.Bytes())
+	return secp256k1.NewPublicKey(&x, &y).SerializeCompressed()
+}
+
+func (secp256k1Curve) ParsePubKey(pubKey []byte) (*ecdsa.PublicKey, error) {
+	if len(pubKey) != pubKeyLen {
+		return nil, errInvalidPubKey
+	}
+	pub, err := secp256k1.ParsePubKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return pub.ToECDSA(), nil
+}
+
+func (secp256k1Curve) Sign(priv *ecdsa.PrivateKey, hash []byte) ([]byte, error) {
+	key := secp256k1.PrivKeyFromBytes(priv.D.FillBytes(make([]byte, privKeyLen)))
+	defer key.Zero()
+
+	sig := dcrecdsa.Sign(key, hash)
+	r, s := sig.R(), sig.S()
+
+	signature := make([]byte, signatureLen)
+	r.PutBytesUnchecked(signature[:signatureLen/2])
+	s.PutBytesUnchecked(signature[signatureLen/2:])
+
+	return signature, nil
+}
+
+func (secp256k1Curve) Verify(pubKey, hash, signature []byte) bool {
+	if len(pubKey) != pubKeyLen || len(signature) != signatureLen {
+		return false
+	}
+	pub, err := secp256k1.ParsePubKey(pubKey)
+	if err != nil {
+		return false
+	}
+
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(signature[:signatureLen/2]) || s.SetByteSlice(signature[signatureLen/2:]) {
+		return false
+	}
+
+	return dcrecdsa.NewSignature(&r, &s).Verify(hash, pub)
+}
+
+type p256Curve struct{}
+
+func (p256Curve) Name() string {
+	return "P-256"
+}
+
+func (p256Curve) Elliptic() elliptic.Curve {
+	return elliptic.P256()
+}
+
+func (c p256Curve) GenerateKey() (ecdsa.PrivateKey, error) {
+	private, err := ecdsa.GenerateKey(c.Elliptic(), rand.Reader)
+	if err != nil {
+		return ecdsa.PrivateKey{}, err
+	}
+	return *private, nil
+}
+
+func (c p256Curve) PrivateKeyFromBytes(d []byte) ecdsa.PrivateKey {
+	curve := c.Elliptic()
+	private := ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+	private.PublicKey.Curve = curve
+	private.PublicKey.X, private.PublicKey.Y = curve.ScalarBaseMult(d)
+
+	return private
+}
+
+func (c p256Curve) SerializePubKey(pub *ecdsa.PublicKey) []byte {
+	return elliptic.MarshalCompressed(c.Elliptic(), pub.X, pub.Y)
+}
+
+func (c p256Curve) ParsePubKey(pubKey []byte) (*ecdsa.PublicKey, error) {
+	x, y := elliptic.UnmarshalCompressed(c.Elliptic(), pubKey)
+	if x == nil {
+		return nil, errInvalidPubKey
+	}
+	return &ecdsa.PublicKey{Curve: c.Elliptic(), X: x, Y: y}, nil
+}
+
+func (p256Curve) Sign(priv *ecdsa.PrivateKey, hash []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, priv, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := make([]byte, signatureLen)
+	r.FillBytes(signature[:signatureLen/2])
+	s.FillBytes(signature[signatureLen/2:])
+
+	return signature, nil
+}
+
+func (c p256Curve) Verify(pubKey, hash, signature []byte) bool {
+	if len(signature) != signatureLen {
+		return false
+	}
+	pub, err := c.ParsePubKey(pubKey)
+	if err != nil {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(signature[:signatureLen/2])
+	s := new(big.Int).SetBytes(signature[signatureLen/2:])
+
+	return ecdsa.Verify(pub, hash, r, s)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurveSignVerify(t *testing.T) {
+	hash := sha256.Sum256([]byte("message"))
+
+	for _, curve := range []Curve{Secp256k1, P256} {
+		// Keys and signatures used to be split at the wrong index when a
+		// coordinate had leading zeros, look for such a key.
+		for i := 0; i < 2048; i++ {
+			private, err := curve.GenerateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			pubKey := encodePubKey(&private.PublicKey)
+			assert.Len(t, pubKey, pubKeyLen, "%s public key is compressed.", curve.Name())
+
+			signature, err := curve.Sign(&private, hash[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Len(t, signature, signatureLen, "%s signature has a fixed width.", curve.Name())
+			assert.True(t, curve.Verify(pubKey, hash[:], signature), "%s signature verifies.", curve.Name())
+
+			other := sha256.Sum256([]byte("other message"))
+			assert.False(t, curve.Verify(pubKey, other[:], signature), "%s signature is bound to the hash.", curve.Name())
+
+			restored := curve.PrivateKeyFromBytes(private.D.Bytes())
+			assert.Equal(t, pubKey, encodePubKey(&restored.PublicKey))
+
+			if len(private.PublicKey.X.Bytes()) < privKeyLen {
+				break
+			}
+		}
+	}
+}
+
+func TestSigningCurve(t *testing.T) {
+	hash := sha256.Sum256([]byte("message"))
+
+	private, err := activeNet.Curve.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = signHash(&private, hash[:], SigHashAll)
+	assert.Nil(t, err)
+
+	private, err = P256.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = signHash(&private, hash[:], SigHashAll)
+	assert.NotNil(t, err, "Signatures of other curves would never verify.")
+
+	wallet := &Wallet{PrivateKey: private, PublicKey: encodePubKey(&private.PublicKey)}
+	_, err = wallet.SignMessage("message")
+	assert.NotNil(t, err, "Messages are verified on the curve of the network too.")
+}
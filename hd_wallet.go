@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+)
+
+// gapLimit is the number of consecutive unused addresses after which a
+// rescan stops looking for more on a chain.
+const gapLimit = 20
+
+// hdChain is the hierarchical deterministic state of the wallets: the seed
+// every key is derived from and the next unused index of the external and
+// internal chains of the account.
+// When the wallets are encrypted, the seed is kept sealed in EncryptedSeed and
+// Seed is only set while they are unlocked.
+type hdChain struct {
+	Seed          []byte
+	EncryptedSeed []byte
+	Account       uint32
+	NextIndex     [2]uint32
+}
+
+// hdChainData is the stored form of an hdChain.
+type hdChainData struct {
+	Seed          []byte
+	EncryptedSeed []byte
+	Account       uint32
+	NextIndex     [2]uint32
+}
+
+// GobEncode encodes the chain. The seed is left out once it has been
+// encrypted.
+func (c hdChain) GobEncode() ([]byte, error) {
+	data := hdChainData(c)
+	if c.EncryptedSeed != nil {
+		data.Seed = nil
+	}
+
+	var buff bytes.Buffer
+	err := gob.NewEncoder(&buff).Encode(data)
+	return buff.Bytes(), err
+}
+
+// GobDecode decodes a chain encoded by GobEncode.
+func (c *hdChain) GobDecode(b []byte) error {
+	var data hdChainData
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data)
+	if err != nil {
+		return err
+	}
+	*c = hdChain(data)
+
+	return nil
+}
+
+// encrypt seals the seed with the wallets master key.
+func (c *hdChain) encrypt(key []byte) error {
+	encrypted, err := seal(key, c.Seed, nil)
+	if err != nil {
+		return err
+	}
+	c.EncryptedSeed = encrypted
+
+	return nil
+}
+
+// decrypt restores the seed sealed with the wallets master key.
+func (c *hdChain) decrypt(key []byte) error {
+	seed, err := open(key, c.EncryptedSeed, nil)
+	if err != nil {
+		return err
+	}
+	c.Seed = seed
+
+	return nil
+}
+
+// deriveWallet derives the wallet at index of a chain of the account. It
+// returns errInvalidChild only when the key at index is invalid and the next
+// index has to be used.
+func (c *hdChain) deriveWallet(chain, index uint32) (*Wallet, error) {
+	var parent *ExtendedKey
+	master, err := NewMasterKey(c.Seed)
+	if err == nil {
+		parent, err = master.Derive(append(accountPath(c.Account), chain)...)
+	}
+	if err == errInvalidChild {
+		// Skipping indexes cannot help when a parent key is invalid.
+		return nil, fmt.Errorf("cannot derive chain %d of account %d: %v", chain, c.Account, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parent.Child(index)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Wallet(), nil
+}
+
+// nextWallet derives the next unused wallet of a chain. Only non-hardened
+// indexes are handed out.
+func (c *hdChain) nextWallet(chain uint32) *Wallet {
+	for c.NextIndex[chain] < hardenedKeyStart {
+		index := c.NextIndex[chain]
+		c.NextIndex[chain]++
+
+		wallet, err := c.deriveWallet(chain, index)
+		if err == errInvalidChild {
+			continue
+		}
+		if err != nil {
+			log.Panic(err)
+		}
+
+		return wallet
+	}
+
+	log.Panicf("error: every key of chain %d has been used", chain)
+	return nil
+}
+
+// IsHD checks whether keys of the wallets are derived from a seed.
+func (ws *Wallets) IsHD() bool {
+	return ws.HDChain != nil
+}
+
+// SetHDSeed makes new keys of the wallets derive from the seed encoded by
+// mnemonic.
+func (ws *Wallets) SetHDSeed(mnemonic string) error {
+	if ws.IsLocked() {
+		return errWalletLocked
+	}
+
+	seed, err := SeedFromMnemonic(mnemonic)
+	if err != nil {
+		return err
+	}
+
+	chain := &hdChain{Seed: seed}
+	if ws.IsEncrypted() {
+		err := chain.encrypt(ws.unlockedKey)
+		if err != nil {
+			return err
+		}
+	}
+	ws.HDChain = chain
+
+	return nil
+}
+
+// CreateChangeWallet creates a Wallet on the internal chain to receive
+// change and adds it to Wallets.
+func (ws *Wallets) CreateChangeWallet() string {
+	return ws.createWallet(internalChain)
+}
+
+// Rescan looks for addresses of both chains that received coins in bc and
+// adds them to Wallets. A chain is scanned until gapLimit consecutive
+// addresses were never used.
+// It returns the addresses found.
+func (ws *Wallets) Rescan(bc *Blockchain) []string {
+	if !ws.IsHD() {
+		return nil
+	}
+	if ws.IsLocked() {
+		log.Panic(errWalletLocked)
+	}
+
+	used := bc.FindUsedPubKeyHashes()
+	var found []string
+
+	for _, chain := range []uint32{externalChain, internalChain} {
+		gap := 0
+		for index := uint32(0); gap < gapLimit && index < hardenedKeyStart; index++ {
+			wallet, err := ws.HDChain.deriveWallet(chain, index)
+			if err == errInvalidChild {
+				continue
+			}
+			if err != nil {
+				log.Panic(err)
+			}
+
+			if !used[string(HashPubKey(wallet.PublicKey))] {
+				gap++
+				continue
+			}
+			gap = 0
+
+			address := ws.addWallet(wallet)
+			ws.Metadata[address].Change = chain == internalChain
+			found = append(found, address)
+			if index >= ws.HDChain.NextIndex[chain] {
+				ws.HDChain.NextIndex[chain] = index + 1
+			}
+		}
+	}
+
+	return found
+}
+
+// addWallet adds wallet to Wallets, sealing its key if they are encrypted.
+func (ws *Wallets) addWallet(wallet *Wallet) string {
+	if ws.IsEncrypted() {
+		err := wallet.encrypt(ws.unlockedKey)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+	address := fmt.Sprintf("%s", wallet.GetAddress())
+
+	ws.Wallets[address] = wallet
+	ws.addMetadata(address, false)
+	return address
+}
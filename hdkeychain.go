@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	bip39 "github.com/tyler-smith/go-bip39"
+)
+
+const (
+	// hardenedKeyStart is the index of the first hardened child key.
+	hardenedKeyStart = uint32(0x80000000)
+	// hdPurpose is the BIP44 purpose of the derivation path.
+	hdPurpose = 44
+	// mnemonicEntropyBits yields a 12 word mnemonic.
+	mnemonicEntropyBits = 128
+
+	// Chains of an account. Addresses handed out to receive coins are on the
+	// external chain, change is sent to addresses of the internal chain.
+	externalChain = 0
+	internalChain = 1
+)
+
+var (
+	masterKeyHMACKey = []byte("Bitcoin seed")
+	errInvalidChild  = errors.New("derived key is invalid, use the next index")
+)
+
+// ExtendedKey is a private key along with the chain code used to derive its
+// children, as described in BIP32.
+type ExtendedKey struct {
+	Key       []byte
+	ChainCode []byte
+}
+
+// NewMnemonic generates a random mnemonic sentence encoding a new seed.
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// SeedFromMnemonic validates mnemonic and returns the seed it encodes.
+func SeedFromMnemonic(mnemonic string) ([]byte, error) {
+	return bip39.NewSeedWithErrorChecking(mnemonic, "")
+}
+
+// NewMasterKey derives the root key of the key tree from seed.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	mac := hmac.New(sha512.New, masterKeyHMACKey)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key := &ExtendedKey{Key: sum[:32], ChainCode: sum[32:]}
+	k := new(big.Int).SetBytes(key.Key)
+	if k.Sign() == 0 || k.Cmp(activeNet.Curve.Elliptic().Params().N) >= 0 {
+		return nil, errInvalidChild
+	}
+
+	return key, nil
+}
+
+// Child derives the child key at index. Indexes from hardenedKeyStart up
+// derive hardened keys, which cannot be linked to the parent public key.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	curve := activeNet.Curve
+
+	var data []byte
+	if index >= hardenedKeyStart {
+		data = append([]byte{0x00}, k.Key...)
+	} else {
+		private := curve.PrivateKeyFromBytes(k.Key)
+		data = curve.SerializePubKey(&private.PublicKey)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := curve.Elliptic().Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return nil, errInvalidChild
+	}
+
+	childKey := il.Add(il, new(big.Int).SetBytes(k.Key))
+	childKey.Mod(childKey, n)
+	if childKey.Sign() == 0 {
+		return nil, errInvalidChild
+	}
+
+	return &ExtendedKey{Key: childKey.FillBytes(make([]byte, privKeyLen)), ChainCode: sum[32:]}, nil
+}
+
+// Derive follows path down the key tree.
+func (k *ExtendedKey) Derive(path ...uint32) (*ExtendedKey, error) {
+	key := k
+	for _, index := range path {
+		var err error
+		key, err = key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// Wallet returns a wallet holding the key.
+func (k *ExtendedKey) Wallet() *Wallet {
+	private := activeNet.Curve.PrivateKeyFromBytes(k.Key)
+	return &Wallet{
+		PrivateKey: private,
+		PublicKey:  encodePubKey(&private.PublicKey),
+	}
+}
+
+// accountPath returns the BIP44 path of an account of the active network.
+func accountPath(account uint32) []uint32 {
+	return []uint32{
+		hardenedKeyStart + hdPurpose,
+		hardenedKeyStart + activeNet.HDCoinType,
+		hardenedKeyStart + account,
+	}
+}
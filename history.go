@@ -0,0 +1,156 @@
+package main
+
+import "bytes"
+
+// AddressTransaction is a transaction in the history of an address.
+type AddressTransaction struct {
+	Txid []byte
+	// Height is the height of the block including the transaction, or -1
+	// while it is unconfirmed.
+	Height        int
+	Confirmations int
+	// Amount is the change of the balance of the address: what the outputs
+	// pay to it less what the inputs spend from it.
+	Amount int
+	// Fee is what the inputs are worth beyond the outputs, 0 for a coinbase.
+	Fee int
+	// Counterparties are the addresses paid if the address spends in the
+	// transaction, the addresses spending otherwise.
+	Counterparties []string
+}
+
+// ListTransactions returns the transactions paying to or spending from
+// address, oldest first, followed by the unconfirmed ones. It scans the
+// whole blockchain, unconfirmed transactions conflicting with it are left
+// out.
+func (bc *Blockchain) ListTransactions(address string) ([]AddressTransaction, error) {
+	lockScript, err := PayToAddrScript(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*Block
+	bci := bc.Iterator()
+	for {
+		block := bci.Next()
+		blocks = append(blocks, block)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	bestHeight := blocks[0].Height
+
+	// outputs holds every output seen, inputs only reference their outpoint.
+	outputs := make(map[string]TXOutput)
+	spent := make(map[string]bool)
+	var history []AddressTransaction
+
+	for i := len(blocks) - 1; i >= 0; i-- {
+		block := blocks[i]
+		for _, tx := range block.Transactions {
+			recordOutputs(tx, outputs)
+			if !tx.IsCoinbase() {
+				for _, vin := range tx.Vin {
+					spent[OutPoint{vin.Txid, vin.Vout}.String()] = true
+				}
+			}
+
+			if entry, ok := newAddressTransaction(tx, lockScript, outputs); ok {
+				entry.Height = block.Height
+				entry.Confirmations = bestHeight - block.Height + 1
+				history = append(history, entry)
+			}
+		}
+	}
+
+	// Unconfirmed transactions may spend each other's outputs.
+	pending := bc.FindPending()
+	for i := range pending {
+		recordOutputs(&pending[i], outputs)
+	}
+	for i := range pending {
+		tx := &pending[i]
+		conflicts := false
+		for _, vin := range tx.Vin {
+			conflicts = conflicts || spent[OutPoint{vin.Txid, vin.Vout}.String()]
+		}
+		if conflicts {
+			continue
+		}
+
+		if entry, ok := newAddressTransaction(tx, lockScript, outputs); ok {
+			entry.Height = -1
+			history = append(history, entry)
+		}
+	}
+
+	return history, nil
+}
+
+// recordOutputs adds the outputs of tx to outputs, by outpoint.
+func recordOutputs(tx *Transaction, outputs map[string]TXOutput) {
+	for i, out := range tx.Vout {
+		outputs[OutPoint{tx.ID, i}.String()] = out
+	}
+}
+
+// newAddressTransaction computes what tx means to the owner of lockScript.
+// It reports false if tx neither pays to nor spends from lockScript. outputs
+// holds the outputs tx spends.
+func newAddressTransaction(tx *Transaction, lockScript []byte, outputs map[string]TXOutput) (AddressTransaction, bool) {
+	var (
+		involved, spends   bool
+		senders, receivers []string
+		in, out            int
+	)
+	entry := AddressTransaction{Txid: tx.ID}
+
+	if !tx.IsCoinbase() {
+		for _, vin := range tx.Vin {
+			prevOut := outputs[OutPoint{vin.Txid, vin.Vout}.String()]
+			in += prevOut.Value
+			if bytes.Equal(prevOut.ScriptPubKey, lockScript) {
+				involved, spends = true, true
+				entry.Amount -= prevOut.Value
+				continue
+			}
+			senders = appendAddress(senders, ExtractAddress(prevOut.ScriptPubKey))
+		}
+	}
+	for _, output := range tx.Vout {
+		out += output.Value
+		if bytes.Equal(output.ScriptPubKey, lockScript) {
+			involved = true
+			entry.Amount += output.Value
+			continue
+		}
+		receivers = appendAddress(receivers, ExtractAddress(output.ScriptPubKey))
+	}
+	if !involved {
+		return AddressTransaction{}, false
+	}
+
+	if !tx.IsCoinbase() {
+		entry.Fee = in - out
+	}
+	entry.Counterparties = senders
+	if spends {
+		entry.Counterparties = receivers
+	}
+	return entry, true
+}
+
+// appendAddress appends address to addresses unless it is empty, for
+// outputs without an address, or already there.
+func appendAddress(addresses []string, address string) []string {
+	if address == "" {
+		return addresses
+	}
+	for _, a := range addresses {
+		if a == address {
+			return addresses
+		}
+	}
+	return append(addresses, address)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+// testBlockchain returns a regtest blockchain stored in a temporary
+// database, its genesis block rewarding address.
+func testBlockchain(t *testing.T, address string) *Blockchain {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "blockchain.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	genesis := NewGenesisBlock(NewCoinbaseTX(address, activeNet.GenesisCoinbaseData))
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(blocksBucket))
+		if err != nil {
+			return err
+		}
+		if err := b.Put(genesis.Hash, genesis.Serialize()); err != nil {
+			return err
+		}
+		return b.Put([]byte("l"), genesis.Hash)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bc := &Blockchain{genesis.Hash, db}
+	UTXOSet{bc}.Reindex()
+	return bc
+}
+
+func TestListTransactions(t *testing.T) {
+	activeNet = &RegTestParams
+	defer func() { activeNet = &MainNetParams }()
+
+	alice, bob, miner := NewWallet(), NewWallet(), string(NewWallet().GetAddress())
+	aliceAddr, bobAddr := string(alice.GetAddress()), string(bob.GetAddress())
+	bc := testBlockchain(t, aliceAddr)
+	UTXOSet := UTXOSet{bc}
+
+	payment := NewSendManyTransaction(alice, []Payment{{bobAddr, 4}}, 0, &CoinControl{FeeRate: 7}, &UTXOSet)
+	fee := activeNet.Subsidy - 4 - payment.Vout[1].Value
+	assert.True(t, fee > 0)
+	UTXOSet.Update(bc.MineBlock([]*Transaction{NewCoinbaseTX(miner, "Block 1"), payment}))
+
+	refund := NewSendManyTransaction(bob, []Payment{{aliceAddr, 1}}, 0, &CoinControl{}, &UTXOSet)
+	bc.AddPending(refund)
+
+	history, err := bc.ListTransactions(aliceAddr)
+	assert.Nil(t, err)
+	if assert.Len(t, history, 3) {
+		assert.Equal(t, AddressTransaction{
+			Txid: history[0].Txid, Height: 0, Confirmations: 2, Amount: activeNet.Subsidy,
+		}, history[0], "The genesis reward.")
+		assert.Equal(t, AddressTransaction{
+			Txid: payment.ID, Height: 1, Confirmations: 1, Amount: -4 - fee, Fee: fee,
+			Counterparties: []string{bobAddr},
+		}, history[1])
+		assert.Equal(t, AddressTransaction{
+			Txid: refund.ID, Height: -1, Amount: 1, Counterparties: []string{bobAddr},
+		}, history[2])
+	}
+
+	history, err = bc.ListTransactions(bobAddr)
+	assert.Nil(t, err)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, 4, history[0].Amount)
+		assert.Equal(t, []string{aliceAddr}, history[0].Counterparties)
+		assert.Equal(t, -1, history[1].Amount)
+	}
+
+	// Once mined, the refund is confirmed.
+	UTXOSet.Update(bc.MineBlock([]*Transaction{NewCoinbaseTX(miner, "Block 2"), refund}))
+	assert.Empty(t, bc.FindPending())
+	history, err = bc.ListTransactions(aliceAddr)
+	assert.Nil(t, err)
+	if assert.Len(t, history, 3) {
+		assert.Equal(t, 3, history[0].Confirmations)
+		assert.Equal(t, 2, history[2].Height)
+		assert.Equal(t, 1, history[2].Confirmations)
+	}
+
+	_, err = bc.ListTransactions("invalid")
+	assert.NotNil(t, err)
+}
+
+func TestPendingConflicts(t *testing.T) {
+	activeNet = &RegTestParams
+	defer func() { activeNet = &MainNetParams }()
+
+	alice, carol := NewWallet(), NewWallet()
+	bob, miner := string(NewWallet().GetAddress()), string(NewWallet().GetAddress())
+	bc := testBlockchain(t, string(alice.GetAddress()))
+	UTXOSet := UTXOSet{bc}
+
+	payment := NewSendManyTransaction(alice, []Payment{{bob, 4}}, 0, &CoinControl{}, &UTXOSet)
+	forged := *payment
+	forged.Vout = []TXOutput{*NewTXOutput(9, bob)}
+	forged.ID = forged.ComputeID()
+	assert.NotNil(t, UTXOSet.CheckPending(&forged), "The signature does not cover these outputs.")
+
+	assert.Nil(t, UTXOSet.CheckPending(payment))
+	bc.AddPending(payment)
+	assert.Nil(t, UTXOSet.CheckPending(payment), "Relaying a transaction again is harmless.")
+
+	doubleSpend := NewSendManyTransaction(alice, []Payment{{string(carol.GetAddress()), 4}}, 0, &CoinControl{}, &UTXOSet)
+	assert.NotNil(t, UTXOSet.CheckPending(doubleSpend), "The coin is spent by the payment.")
+
+	// Pending transactions conflicting with a block are forgotten, along
+	// with those spending their outputs.
+	child := &Transaction{
+		Vin:  []TXInput{{Txid: doubleSpend.ID, Vout: 0, Sequence: sequenceFinal}},
+		Vout: []TXOutput{*NewTXOutput(4, bob)},
+	}
+	child.ID = child.ComputeID()
+	child.Sign(carol.PrivateKey, map[string]Transaction{hex.EncodeToString(doubleSpend.ID): *doubleSpend})
+	bc.AddPending(doubleSpend)
+	bc.AddPending(child)
+	assert.Len(t, bc.FindPending(), 3)
+
+	UTXOSet.Update(bc.MineBlock([]*Transaction{NewCoinbaseTX(miner, "Block 1"), payment}))
+	assert.Empty(t, bc.FindPending())
+}
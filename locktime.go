@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Input sequence numbers. An input whose sequence is not final enables the
+// lock time of its transaction. Unless sequenceLockTimeDisabled is set, the
+// sequence also holds a relative lock: the number of blocks, or of 512
+// seconds periods, that must pass after the output it spends was mined.
+const (
+	sequenceFinal = 0xffffffff
+	// sequenceLockTimeEnabled is the sequence of inputs that enable the
+	// lock time of their transaction without a relative lock.
+	sequenceLockTimeEnabled = sequenceFinal - 1
+
+	sequenceLockTimeDisabled    = 1 << 31
+	sequenceLockTimeIsSeconds   = 1 << 22
+	sequenceLockTimeMask        = 0x0000ffff
+	sequenceLockTimeGranularity = 9
+)
+
+// IsFinal checks whether tx may be included in a block at height with
+// timestamp blockTime. Its lock time must have passed, unless every input is
+// final.
+func (tx *Transaction) IsFinal(height int, blockTime int64) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+
+	limit := int64(height)
+	if tx.LockTime >= lockTimeThreshold {
+		limit = blockTime
+	}
+	if int64(tx.LockTime) < limit {
+		return true
+	}
+
+	for _, vin := range tx.Vin {
+		if vin.Sequence != sequenceFinal {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSequenceLock checks the relative lock of an input with sequence,
+// spending an output mined at prevHeight with timestamp prevTime, in a block
+// at height with timestamp blockTime.
+func checkSequenceLock(sequence uint32, prevHeight int, prevTime int64, height int, blockTime int64) error {
+	if sequence&sequenceLockTimeDisabled != 0 {
+		return nil
+	}
+
+	value := sequence & sequenceLockTimeMask
+	if sequence&sequenceLockTimeIsSeconds != 0 {
+		minTime := prevTime + int64(value)<<sequenceLockTimeGranularity
+		if blockTime < minTime {
+			return fmt.Errorf("input is locked until %d", minTime)
+		}
+		return nil
+	}
+
+	minHeight := prevHeight + int(value)
+	if height < minHeight {
+		return fmt.Errorf("input is locked until height %d", minHeight)
+	}
+	return nil
+}
+
+// minedTx tells where a transaction was mined: the height and the timestamp
+// of its block.
+type minedTx struct {
+	Height int
+	Time   int64
+}
+
+// minedFunc finds where the transaction with ID was mined.
+type minedFunc func(ID []byte) (minedTx, error)
+
+// CheckLocks checks that tx may be included in the block following the tip,
+// at height with timestamp blockTime: it must be final and the relative locks
+// of its inputs must have expired. Unconfirmed transactions it spends can be
+// mined in the same block at the earliest.
+func (bc *Blockchain) CheckLocks(tx *Transaction, height int, blockTime int64) error {
+	pending := make(map[string]bool)
+	for _, pendingTx := range bc.FindPending() {
+		pending[hex.EncodeToString(pendingTx.ID)] = true
+	}
+
+	mined := func(ID []byte) (minedTx, error) {
+		if pending[hex.EncodeToString(ID)] {
+			return minedTx{height, blockTime}, nil
+		}
+		return UTXOSet{bc}.findMined(ID)
+	}
+	return checkLocks([]*Transaction{tx}, height, blockTime, mined)
+}
+
+// checkLocks checks that txs may be included, in order, in a block at height
+// with timestamp blockTime. Outputs of transactions earlier in txs are mined
+// in that block, mined finds where the others were.
+func checkLocks(txs []*Transaction, height int, blockTime int64, mined minedFunc) error {
+	inBlock := make(map[string]minedTx)
+
+	for _, tx := range txs {
+		if !tx.IsFinal(height, blockTime) {
+			return fmt.Errorf("transaction %x is locked until %d", tx.ID, tx.LockTime)
+		}
+
+		for inIdx, vin := range tx.Vin {
+			if tx.IsCoinbase() || vin.Sequence&sequenceLockTimeDisabled != 0 {
+				continue
+			}
+
+			prev, ok := inBlock[hex.EncodeToString(vin.Txid)]
+			if !ok {
+				var err error
+				prev, err = mined(vin.Txid)
+				if err != nil {
+					return fmt.Errorf("transaction %x input %d: %v", tx.ID, inIdx, err)
+				}
+			}
+			err := checkSequenceLock(vin.Sequence, prev.Height, prev.Time, height, blockTime)
+			if err != nil {
+				return fmt.Errorf("transaction %x input %d: %v", tx.ID, inIdx, err)
+			}
+		}
+
+		inBlock[hex.EncodeToString(tx.ID)] = minedTx{height, blockTime}
+	}
+
+	return nil
+}
+
+// checkBlockLocks checks the locks of the transactions of block before it
+// becomes the tip. A block extending the tip is checked against the UTXO set.
+// Otherwise the chain switches to the branch of block, and every block of the
+// branch since it forked is checked against the branch itself.
+func (bc *Blockchain) checkBlockLocks(block *Block) error {
+	if bytes.Equal(block.PrevBlockHash, bc.tip) {
+		return checkLocks(block.Transactions, block.Height, block.Timestamp, UTXOSet{bc}.findMined)
+	}
+
+	branch, err := bc.findBranch(block)
+	if err != nil {
+		return err
+	}
+	for _, b := range branch {
+		mined := func(ID []byte) (minedTx, error) {
+			return bc.findMinedFrom(ID, b.PrevBlockHash)
+		}
+
+		err := checkLocks(b.Transactions, b.Height, b.Timestamp, mined)
+		if err != nil {
+			return fmt.Errorf("block %x: %v", b.Hash, err)
+		}
+	}
+
+	return nil
+}
+
+// findMined finds where the transaction with ID, which must have unspent
+// outputs, was mined.
+func (u UTXOSet) findMined(ID []byte) (minedTx, error) {
+	var outs TXOutputs
+
+	err := u.Blockchain.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		outsBytes := b.Get(ID)
+		if outsBytes != nil {
+			outs = DeserializeOutputs(outsBytes)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if len(outs.Outputs) == 0 {
+		return minedTx{}, fmt.Errorf("transaction %x has no unspent outputs", ID)
+	}
+	if outs.Time == 0 {
+		return minedTx{}, errors.New("the UTXO set has no block times, run reindexutxo")
+	}
+	return minedTx{outs.Height, outs.Time}, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionIsFinal(t *testing.T) {
+	tx, _ := spendingTx(nil)
+	tx.Vin[0].Sequence = sequenceLockTimeEnabled
+	assert.True(t, tx.IsFinal(1, 0), "No lock time.")
+
+	tx.LockTime = 100
+	assert.False(t, tx.IsFinal(100, 0), "Locked until the block after height 100.")
+	assert.True(t, tx.IsFinal(101, 0))
+
+	tx.LockTime = 1500000000
+	assert.False(t, tx.IsFinal(1000, 1500000000), "Locked by time, not height.")
+	assert.True(t, tx.IsFinal(1, 1500000001))
+
+	tx.Vin[0].Sequence = sequenceFinal
+	assert.True(t, tx.IsFinal(1, 0), "Final inputs disable the lock time.")
+}
+
+func TestCheckSequenceLock(t *testing.T) {
+	assert.NotNil(t, checkSequenceLock(10, 5, 0, 14, 0), "Locked for 10 blocks after height 5.")
+	assert.Nil(t, checkSequenceLock(10, 5, 0, 15, 0))
+
+	seconds := uint32(sequenceLockTimeIsSeconds | 2)
+	assert.NotNil(t, checkSequenceLock(seconds, 5, 1000, 100, 1000+1023), "Locked for 2 periods of 512 seconds.")
+	assert.Nil(t, checkSequenceLock(seconds, 5, 1000, 6, 1000+1024))
+
+	assert.Nil(t, checkSequenceLock(sequenceLockTimeDisabled|10, 5, 0, 6, 0), "Disabled relative lock.")
+	assert.Nil(t, checkSequenceLock(sequenceFinal, 5, 0, 6, 0), "Final inputs have no relative lock.")
+}
+
+func TestAddBlockChecksLocks(t *testing.T) {
+	activeNet = &RegTestParams
+	defer func() { activeNet = &MainNetParams }()
+
+	alice, miner := NewWallet(), NewWallet()
+	bc := testBlockchain(t, string(alice.GetAddress()))
+	genesis := bc.Iterator().Next()
+
+	coinbase := func(data string) *Transaction {
+		return NewCoinbaseTX(string(miner.GetAddress()), data)
+	}
+	// spending spends the first output of prev, locked for blocks after
+	// prev was mined.
+	spending := func(prev *Transaction, blocks uint32) *Transaction {
+		tx := &Transaction{
+			Vin:  []TXInput{{prev.ID, 0, nil, blocks}},
+			Vout: []TXOutput{*NewTXOutput(prev.Vout[0].Value, string(miner.GetAddress()))},
+		}
+		tx.ID = tx.ComputeID()
+		return tx
+	}
+
+	UTXOSet{bc}.Update(bc.MineBlock([]*Transaction{coinbase("Block 1")}))
+	tip := bc.tip
+
+	// Side branch blocks are checked once the branch becomes the chain.
+	locked := NewBlock([]*Transaction{coinbase("Locked 1"), spending(genesis.Transactions[0], 5)}, genesis.Hash, 1)
+	bc.AddBlock(locked)
+	bc.AddBlock(NewBlock([]*Transaction{coinbase("Locked 2")}, locked.Hash, 2))
+	assert.Equal(t, tip, bc.tip, "The branch mines a locked input at height 1.")
+
+	unlocked := NewBlock([]*Transaction{coinbase("Unlocked 1"), spending(genesis.Transactions[0], 1)}, genesis.Hash, 1)
+	bc.AddBlock(unlocked)
+	cbTx := coinbase("Unlocked 2")
+	next := NewBlock([]*Transaction{cbTx}, unlocked.Hash, 2)
+	bc.AddBlock(next)
+	assert.Equal(t, next.Hash, bc.tip)
+	UTXOSet{bc}.Reindex()
+
+	// Blocks extending the tip are checked against the UTXO set, outputs of
+	// the block itself are mined at its height.
+	bc.AddBlock(NewBlock([]*Transaction{coinbase("Block 3"), spending(cbTx, 2)}, next.Hash, 3))
+	assert.Equal(t, next.Hash, bc.tip, "Locked until height 4.")
+
+	first := spending(cbTx, 1)
+	block := NewBlock([]*Transaction{coinbase("Block 3"), first, spending(first, 0)}, next.Hash, 3)
+	bc.AddBlock(block)
+	assert.Equal(t, block.Hash, bc.tip)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// pendingBucket stores the transactions broadcast or relayed by the node
+// that are not in a block yet, so that wallet commands run after the node
+// stopped still see them.
+const pendingBucket = "pending"
+
+// AddPending records tx as unconfirmed until a block includes it.
+func (bc *Blockchain) AddPending(tx *Transaction) {
+	err := bc.db.Update(func(dbTx *bolt.Tx) error {
+		b, err := dbTx.CreateBucketIfNotExists([]byte(pendingBucket))
+		if err != nil {
+			return err
+		}
+		return b.Put(tx.ID, tx.Serialize())
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// FindPending returns the unconfirmed transactions.
+func (bc *Blockchain) FindPending() []Transaction {
+	var txs []Transaction
+
+	err := bc.db.View(func(dbTx *bolt.Tx) error {
+		b := dbTx.Bucket([]byte(pendingBucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			txs = append(txs, DeserializeTransaction(v))
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return txs
+}
+
+// CheckPending checks that tx may join the unconfirmed transactions: it has
+// to spend, with valid signatures, unspent outputs or outputs of other
+// unconfirmed transactions that no other unconfirmed transaction spends.
+func (u UTXOSet) CheckPending(tx *Transaction) error {
+	pending := make(map[string]Transaction)
+	spentBy := make(map[string][]byte)
+	for _, pendingTx := range u.Blockchain.FindPending() {
+		if bytes.Equal(pendingTx.ID, tx.ID) {
+			continue
+		}
+		pending[hex.EncodeToString(pendingTx.ID)] = pendingTx
+		for _, vin := range pendingTx.Vin {
+			spentBy[OutPoint{vin.Txid, vin.Vout}.String()] = pendingTx.ID
+		}
+	}
+
+	for _, vin := range tx.Vin {
+		outPoint := OutPoint{vin.Txid, vin.Vout}
+		if txID := spentBy[outPoint.String()]; txID != nil {
+			return fmt.Errorf("%s is already spent by unconfirmed transaction %x", outPoint, txID)
+		}
+	}
+	if !u.verifyPending(tx, pending) {
+		return errors.New("transaction is not valid")
+	}
+	return nil
+}
+
+// verifyPending checks that tx spends, with valid signatures, unspent
+// outputs or outputs of the unconfirmed transactions of pending, keyed by
+// their hex-encoded ID.
+func (u UTXOSet) verifyPending(tx *Transaction, pending map[string]Transaction) bool {
+	if tx.IsCoinbase() {
+		return false
+	}
+
+	prevTXs := make(map[string]Transaction)
+	for _, vin := range tx.Vin {
+		txID := hex.EncodeToString(vin.Txid)
+		if prevTx, ok := pending[txID]; ok {
+			prevTXs[txID] = prevTx
+			continue
+		}
+
+		if _, ok := u.FindCoin(OutPoint{vin.Txid, vin.Vout}); !ok {
+			return false
+		}
+		prevTx, err := u.Blockchain.FindTransaction(vin.Txid)
+		if err != nil {
+			return false
+		}
+		prevTXs[txID] = prevTx
+	}
+
+	return tx.Verify(prevTXs)
+}
+
+// removePending forgets the unconfirmed transactions block includes, and
+// those conflicting with it: spending an output the block spends, or an
+// output of a forgotten conflicting transaction.
+func removePending(dbTx *bolt.Tx, block *Block) error {
+	b := dbTx.Bucket([]byte(pendingBucket))
+	if b == nil {
+		return nil
+	}
+
+	spent := make(map[string]bool)
+	for _, tx := range block.Transactions {
+		if err := b.Delete(tx.ID); err != nil {
+			return err
+		}
+		for _, vin := range tx.Vin {
+			spent[OutPoint{vin.Txid, vin.Vout}.String()] = true
+		}
+	}
+
+	// Forgetting a transaction makes those spending its outputs conflict too.
+	evicted := make(map[string]bool)
+	for {
+		var conflicts [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			tx := DeserializeTransaction(v)
+			for _, vin := range tx.Vin {
+				if spent[OutPoint{vin.Txid, vin.Vout}.String()] || evicted[string(vin.Txid)] {
+					conflicts = append(conflicts, append([]byte(nil), k...))
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(conflicts) == 0 {
+			return nil
+		}
+
+		for _, txID := range conflicts {
+			if err := b.Delete(txID); err != nil {
+				return err
+			}
+			evicted[string(txID)] = true
+		}
+	}
+}
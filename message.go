@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// messageMagic prefixes signed messages, so that signing a message never
+// signs a transaction.
+const messageMagic = "Blockchain Signed Message:\n"
+
+var errInvalidMessageSignature = errors.New("message signature is not the Base64 encoding of a public key and a signature")
+
+// messageHash returns the hash signed for message: the double SHA-256 of
+// messageMagic and message, each prefixed with its length.
+func messageHash(message string) []byte {
+	var buf bytes.Buffer
+	writeBytes(&buf, []byte(messageMagic))
+	writeBytes(&buf, []byte(message))
+
+	first := sha256.Sum256(buf.Bytes())
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+// SignMessage signs message with the private key of the wallet, proving
+// that its owner controls its address. The signature is the Base64 encoding
+// of the public key followed by the signature, so that it can be checked
+// with the address alone.
+func (w *Wallet) SignMessage(message string) (string, error) {
+	if w.PrivateKey.D == nil {
+		return "", errWalletLocked
+	}
+
+	curve, err := signingCurve(&w.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	signature, err := curve.Sign(&w.PrivateKey, messageHash(message))
+	if err != nil {
+		return "", err
+	}
+
+	data := append(append([]byte(nil), w.PublicKey...), signature...)
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// VerifyMessage checks that signature was made by SignMessage for message
+// with the key of the address, which pays to a public key hash.
+func VerifyMessage(address, signature, message string) (bool, error) {
+	script, err := PayToAddrScript(address)
+	if err != nil {
+		return false, err
+	}
+	pubKeyHash := extractPubKeyHash(scriptCode(script))
+	if pubKeyHash == nil {
+		return false, fmt.Errorf("error: %s does not pay to a public key hash", address)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(data) != pubKeyLen+signatureLen {
+		return false, errInvalidMessageSignature
+	}
+	pubKey := data[:pubKeyLen]
+	if !bytes.Equal(HashPubKey(pubKey), pubKeyHash) {
+		return false, nil
+	}
+
+	return activeNet.Curve.Verify(pubKey, messageHash(message), data[pubKeyLen:]), nil
+}
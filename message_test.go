@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignMessage(t *testing.T) {
+	wallet, other := NewWallet(), NewWallet()
+	address := string(wallet.GetAddress())
+
+	signature, err := wallet.SignMessage("I own this address.")
+	assert.Nil(t, err)
+
+	valid, err := VerifyMessage(address, signature, "I own this address.")
+	assert.Nil(t, err)
+	assert.True(t, valid)
+
+	valid, err = VerifyMessage(address, signature, "I own another address.")
+	assert.Nil(t, err)
+	assert.False(t, valid, "The message was changed.")
+
+	valid, err = VerifyMessage(string(other.GetAddress()), signature, "I own this address.")
+	assert.Nil(t, err)
+	assert.False(t, valid, "The key is not the key of the address.")
+
+	// Signatures of anything but the domain-separated hash are rejected.
+	raw, err := wallet.curve().Sign(&wallet.PrivateKey, []byte("I own this address."))
+	assert.Nil(t, err)
+	forged := base64.StdEncoding.EncodeToString(append(append([]byte(nil), wallet.PublicKey...), raw...))
+	valid, err = VerifyMessage(address, forged, "I own this address.")
+	assert.Nil(t, err)
+	assert.False(t, valid)
+
+	for _, invalid := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte{0x01})} {
+		_, err := VerifyMessage(address, invalid, "I own this address.")
+		assert.Equal(t, errInvalidMessageSignature, err, "Signature %q.", invalid)
+	}
+	_, err = VerifyMessage(ScriptHashAddress([]byte{OP_1}), signature, "I own this address.")
+	assert.NotNil(t, err, "Script hash addresses have no key.")
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChainParams bundles the parameters that define a network: its genesis
+// block, address format, difficulty, reward and where its data is stored.
+type ChainParams struct {
+	Name string
+	// Net is the magic number prefixing every message on the wire, so nodes
+	// of different networks never talk to each other.
+	Net         uint32
+	DefaultPort string
+
+	GenesisCoinbaseData string
+	GenesisTimestamp    int64
+
+	// Curve is the curve of the keys signing transactions.
+	Curve Curve
+	// AddressVersion is the version byte of Base58 addresses paying to a
+	// public key hash.
+	AddressVersion byte
+	// ScriptHashVersion is the version byte of Base58 addresses paying to a
+	// script hash.
+	ScriptHashVersion byte
+	// Bech32HRP is the human-readable part of Bech32 addresses, which pay to
+	// witness programs.
+	Bech32HRP string
+	// PrivateKeyVersion is the version byte of private keys in Wallet Import
+	// Format.
+	PrivateKeyVersion byte
+	// HDCoinType is the coin type level of BIP44 derivation paths.
+	HDCoinType uint32
+	TargetBits int
+	Subsidy    int
+	// CoinbaseMaturity is the number of confirmations after which coinbase
+	// outputs count as spendable in balances.
+	CoinbaseMaturity int
+
+	// GenerateSupported allows mining blocks on demand with the generate
+	// command.
+	GenerateSupported bool
+
+	DBFile     string
+	WalletFile string
+}
+
+// MainNetParams defines the main network.
+var MainNetParams = ChainParams{
+	Name:                "mainnet",
+	Net:                 0xd9b4bef9,
+	DefaultPort:         "3000",
+	GenesisCoinbaseData: "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks",
+	GenesisTimestamp:    1231006505,
+	Curve:               Secp256k1,
+	AddressVersion:      0x00,
+	ScriptHashVersion:   0x05,
+	Bech32HRP:           "bc",
+	PrivateKeyVersion:   0x80,
+	HDCoinType:          0,
+	TargetBits:          16,
+	Subsidy:             10,
+	CoinbaseMaturity:    100,
+	DBFile:              "blockchain_%s.db",
+	WalletFile:          "wallet_%s.dat",
+}
+
+// TestNetParams defines the public test network. It mines at a lower
+// difficulty and its addresses start with a T.
+var TestNetParams = ChainParams{
+	Name:                "testnet",
+	Net:                 0x0709110b,
+	DefaultPort:         "13000",
+	GenesisCoinbaseData: "Test network genesis block",
+	GenesisTimestamp:    1296688602,
+	Curve:               Secp256k1,
+	AddressVersion:      0x41,
+	ScriptHashVersion:   0x7f,
+	Bech32HRP:           "tb",
+	PrivateKeyVersion:   0xef,
+	HDCoinType:          1,
+	TargetBits:          12,
+	Subsidy:             10,
+	CoinbaseMaturity:    100,
+	DBFile:              "blockchain_testnet_%s.db",
+	WalletFile:          "wallet_testnet_%s.dat",
+}
+
+// RegTestParams defines the regression test network. Blocks are mined at a
+// trivial difficulty, so a chain can be grown on demand with the generate
+// command.
+var RegTestParams = ChainParams{
+	Name:                "regtest",
+	Net:                 0xdab5bffa,
+	DefaultPort:         "23000",
+	GenesisCoinbaseData: "Regression test genesis block",
+	GenesisTimestamp:    1296688602,
+	Curve:               Secp256k1,
+	AddressVersion:      0x6f,
+	ScriptHashVersion:   0xc4,
+	Bech32HRP:           "bcrt",
+	PrivateKeyVersion:   0xef,
+	HDCoinType:          1,
+	TargetBits:          1,
+	Subsidy:             10,
+	CoinbaseMaturity:    100,
+	GenerateSupported:   true,
+	DBFile:              "blockchain_regtest_%s.db",
+	WalletFile:          "wallet_regtest_%s.dat",
+}
+
+// activeNet is the network the node runs on.
+var activeNet = &MainNetParams
+
+// selectNetwork makes the network with the given name the active one.
+func selectNetwork(name string) error {
+	for _, params := range []*ChainParams{&MainNetParams, &TestNetParams, &RegTestParams} {
+		if strings.EqualFold(params.Name, name) {
+			activeNet = params
+			knownNodes = []string{"localhost:" + params.DefaultPort}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown network %q", name)
+}
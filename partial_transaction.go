@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+)
+
+var errIncompleteTransaction = errors.New("transaction is missing signatures")
+
+// PartialTransaction is a transaction passed between the signers of its
+// inputs. Besides the unsigned transaction it carries what they need to sign
+// without the blockchain, the outputs spent by each input, and the
+// signatures gathered so far. Once every input has enough signatures it is
+// finalized into a transaction ready to be broadcast.
+type PartialTransaction struct {
+	Tx     Transaction
+	Inputs []PartialInput
+}
+
+// PartialInput holds the signing state of an input of a PartialTransaction.
+type PartialInput struct {
+	// PrevOut is the output spent by the input.
+	PrevOut TXOutput
+	// RedeemScript is the script hashing to PrevOut when it pays to a script
+	// hash, or to a version 0 witness script hash.
+	RedeemScript []byte
+	// Signatures maps hex-encoded public keys to their signature.
+	Signatures map[string][]byte
+}
+
+// NewPartialTransaction returns a PartialTransaction for tx, which spends
+// prevOuts. redeemScripts holds the redeem scripts of pay-to-script-hash and
+// witness script hash outputs by the hex-encoded hash of their locking
+// script, as indexed by redeemScriptsByHash.
+func NewPartialTransaction(tx Transaction, prevOuts []TXOutput, redeemScripts map[string][]byte) (*PartialTransaction, error) {
+	if len(prevOuts) != len(tx.Vin) {
+		return nil, fmt.Errorf("got %d previous outputs for %d inputs", len(prevOuts), len(tx.Vin))
+	}
+
+	ptx := &PartialTransaction{Tx: tx.TrimmedCopy()}
+	for _, prevOut := range prevOuts {
+		input := PartialInput{PrevOut: prevOut, Signatures: make(map[string][]byte)}
+
+		scriptHash := extractScriptHash(prevOut.ScriptPubKey)
+		if scriptHash == nil {
+			scriptHash = extractWitnessScriptHash(prevOut.ScriptPubKey)
+		}
+		if scriptHash != nil {
+			input.RedeemScript = redeemScripts[hex.EncodeToString(scriptHash)]
+			if input.RedeemScript == nil {
+				return nil, fmt.Errorf("missing redeem script for %s", ExtractAddress(prevOut.ScriptPubKey))
+			}
+		}
+
+		ptx.Inputs = append(ptx.Inputs, input)
+	}
+
+	return ptx, nil
+}
+
+// NewScriptSpendTransaction builds a PartialTransaction sending amount to
+// address out of the outputs locked by lockScript, the change going back to
+// lockScript. redeemScript is needed when lockScript pays to a script hash.
+func NewScriptSpendTransaction(lockScript, redeemScript []byte, to string, amount int, UTXOSet *UTXOSet) (*PartialTransaction, error) {
+	acc, validOutputs := UTXOSet.FindSpendableOutputs(lockScript, amount)
+	if acc < amount {
+		return nil, errors.New("not enough funds")
+	}
+
+	var (
+		inputs   []TXInput
+		prevOuts []TXOutput
+	)
+	for txid, outs := range validOutputs {
+		txID, err := hex.DecodeString(txid)
+		if err != nil {
+			return nil, err
+		}
+		prevTx, err := UTXOSet.Blockchain.FindTransaction(txID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, out := range outs {
+			inputs = append(inputs, TXInput{Txid: txID, Vout: out, Sequence: sequenceFinal})
+			prevOuts = append(prevOuts, prevTx.Vout[out])
+		}
+	}
+
+	outputs := []TXOutput{*NewTXOutput(amount, to)}
+	if acc > amount {
+		outputs = append(outputs, TXOutput{Value: acc - amount, ScriptPubKey: lockScript})
+	}
+
+	tx := Transaction{Vin: inputs, Vout: outputs}
+	tx.ID = tx.ComputeID()
+
+	var redeemScripts map[string][]byte
+	if redeemScript != nil {
+		redeemScripts = redeemScriptsByHash([][]byte{redeemScript})
+	}
+
+	return NewPartialTransaction(tx, prevOuts, redeemScripts)
+}
+
+// redeemScriptsByHash indexes redeemScripts by the hex-encoded hashes
+// pay-to-script-hash and witness script hash outputs locked to them hold.
+func redeemScriptsByHash(redeemScripts [][]byte) map[string][]byte {
+	scripts := make(map[string][]byte)
+	for _, redeemScript := range redeemScripts {
+		scripts[hex.EncodeToString(HashPubKey(redeemScript))] = redeemScript
+		scripts[hex.EncodeToString(WitnessScriptHash(redeemScript))] = redeemScript
+	}
+	return scripts
+}
+
+// subScript returns the script the signatures of input inIdx commit to.
+func (ptx *PartialTransaction) subScript(inIdx int) []byte {
+	input := ptx.Inputs[inIdx]
+	if input.RedeemScript != nil {
+		return input.RedeemScript
+	}
+	return scriptCode(input.PrevOut.ScriptPubKey)
+}
+
+// signers returns the public key hashes allowed to sign input inIdx.
+func (ptx *PartialTransaction) signers(inIdx int) [][]byte {
+	script := ptx.subScript(inIdx)
+
+	if pubKeyHash := extractPubKeyHash(script); pubKeyHash != nil {
+		return [][]byte{pubKeyHash}
+	}
+
+	var hashes [][]byte
+	if _, pubKeys, ok := extractMultiSig(script); ok {
+		for _, pubKey := range pubKeys {
+			hashes = append(hashes, HashPubKey(pubKey))
+		}
+	}
+
+	return hashes
+}
+
+// Sign adds the signatures the keys of wallets can make, committing to the
+// parts of the transaction selected by hashType. It returns the number of
+// signatures added.
+func (ptx *PartialTransaction) Sign(wallets *Wallets, hashType SigHashType) (int, error) {
+	if wallets.IsLocked() {
+		return 0, errWalletLocked
+	}
+
+	hashes := NewTxSigHashes(&ptx.Tx)
+	signed := 0
+	for inIdx := range ptx.Inputs {
+		for _, pubKeyHash := range ptx.signers(inIdx) {
+			wallet := wallets.FindWallet(pubKeyHash)
+			if wallet == nil {
+				continue
+			}
+			pubKey := hex.EncodeToString(wallet.PublicKey)
+			if ptx.Inputs[inIdx].Signatures[pubKey] != nil {
+				continue
+			}
+
+			hash, err := ptx.Tx.signatureHash(hashes, inIdx, ptx.subScript(inIdx), ptx.Inputs[inIdx].PrevOut.Value, hashType)
+			if err != nil {
+				return signed, err
+			}
+			signature, err := signHash(&wallet.PrivateKey, hash, hashType)
+			if err != nil {
+				return signed, err
+			}
+
+			ptx.Inputs[inIdx].Signatures[pubKey] = signature
+			signed++
+		}
+	}
+
+	return signed, nil
+}
+
+// Combine adds the signatures of other, a copy of the same transaction signed
+// by other keys. The signatures already gathered are kept. The others are
+// only added if they are all valid signatures of a signer of their input.
+func (ptx *PartialTransaction) Combine(other *PartialTransaction) error {
+	if !bytes.Equal(ptx.Tx.WitnessID(), other.Tx.WitnessID()) || len(ptx.Inputs) != len(other.Inputs) {
+		return errors.New("cannot combine signatures of different transactions")
+	}
+
+	hashes := NewTxSigHashes(&ptx.Tx)
+	for inIdx, input := range other.Inputs {
+		for pubKey, signature := range input.Signatures {
+			if ptx.Inputs[inIdx].Signatures[pubKey] == nil && !ptx.checkSignature(hashes, inIdx, pubKey, signature) {
+				return fmt.Errorf("input %d: invalid signature of %s", inIdx, pubKey)
+			}
+		}
+	}
+
+	for inIdx, input := range other.Inputs {
+		for pubKey, signature := range input.Signatures {
+			if ptx.Inputs[inIdx].Signatures[pubKey] == nil {
+				ptx.Inputs[inIdx].Signatures[pubKey] = signature
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkSignature checks that signature, followed by its hash type, was made
+// for input inIdx by pubKey, the hex-encoded key of one of its signers.
+func (ptx *PartialTransaction) checkSignature(hashes *TxSigHashes, inIdx int, pubKey string, signature []byte) bool {
+	pubKeyBytes, err := hex.DecodeString(pubKey)
+	if err != nil || len(signature) != signatureLen+1 {
+		return false
+	}
+
+	signer := false
+	for _, pubKeyHash := range ptx.signers(inIdx) {
+		signer = signer || bytes.Equal(pubKeyHash, HashPubKey(pubKeyBytes))
+	}
+	if !signer {
+		return false
+	}
+
+	hash, err := ptx.Tx.signatureHash(hashes, inIdx, ptx.subScript(inIdx), ptx.Inputs[inIdx].PrevOut.Value, SigHashType(signature[signatureLen]))
+	if err != nil {
+		return false
+	}
+	return activeNet.Curve.Verify(pubKeyBytes, hash, signature[:signatureLen])
+}
+
+// signatureScript builds the signature script of input inIdx from the
+// signatures gathered, or returns nil if there are not enough.
+func (ptx *PartialTransaction) signatureScript(inIdx int) []byte {
+	input := ptx.Inputs[inIdx]
+	script := ptx.subScript(inIdx)
+	builder := NewScriptBuilder()
+
+	if pubKeyHash := extractPubKeyHash(script); pubKeyHash != nil {
+		for pubKey, signature := range input.Signatures {
+			pubKeyBytes, err := hex.DecodeString(pubKey)
+			if err == nil && bytes.Equal(HashPubKey(pubKeyBytes), pubKeyHash) {
+				builder.AddData(signature).AddData(pubKeyBytes)
+				break
+			}
+		}
+	} else if nRequired, pubKeys, ok := extractMultiSig(script); ok {
+		// Signatures have to follow the order of the keys.
+		var count int
+		for _, pubKey := range pubKeys {
+			signature := input.Signatures[hex.EncodeToString(pubKey)]
+			if signature != nil && count < nRequired {
+				builder.AddData(signature)
+				count++
+			}
+		}
+		if count < nRequired {
+			return nil
+		}
+	}
+
+	if len(builder.Script()) == 0 {
+		return nil
+	}
+	if input.RedeemScript != nil {
+		builder.AddData(input.RedeemScript)
+	}
+
+	return builder.Script()
+}
+
+// IsComplete checks whether every input has enough signatures.
+func (ptx *PartialTransaction) IsComplete() bool {
+	for inIdx := range ptx.Inputs {
+		if ptx.signatureScript(inIdx) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Finalize returns the signed transaction once every input has enough
+// signatures. Each input is checked against the output it spends.
+func (ptx *PartialTransaction) Finalize() (*Transaction, error) {
+	tx := ptx.Tx.TrimmedCopy()
+
+	for inIdx := range ptx.Inputs {
+		scriptSig := ptx.signatureScript(inIdx)
+		if scriptSig == nil {
+			return nil, errIncompleteTransaction
+		}
+		tx.Vin[inIdx].ScriptSig = scriptSig
+	}
+
+	hashes := NewTxSigHashes(&tx)
+	for inIdx, input := range ptx.Inputs {
+		err := verifyScript(tx.Vin[inIdx].ScriptSig, input.PrevOut.ScriptPubKey, &tx, inIdx, input.PrevOut.Value, hashes)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %v", inIdx, err)
+		}
+	}
+
+	return &tx, nil
+}
+
+// Serialize returns a serialized PartialTransaction.
+func (ptx PartialTransaction) Serialize() []byte {
+	var buff bytes.Buffer
+
+	enc := gob.NewEncoder(&buff)
+	err := enc.Encode(ptx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+// DeserializePartialTransaction deserializes a PartialTransaction.
+func DeserializePartialTransaction(data []byte) (*PartialTransaction, error) {
+	var ptx PartialTransaction
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&ptx)
+	if err != nil {
+		return nil, err
+	}
+	if len(ptx.Inputs) != len(ptx.Tx.Vin) {
+		return nil, errors.New("partial transaction does not describe every input")
+	}
+	for inIdx := range ptx.Inputs {
+		if ptx.Inputs[inIdx].Signatures == nil {
+			ptx.Inputs[inIdx].Signatures = make(map[string][]byte)
+		}
+	}
+
+	return &ptx, nil
+}
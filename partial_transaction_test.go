@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartialTransactionMultiSig(t *testing.T) {
+	var (
+		signers []*Wallets
+		pubKeys [][]byte
+	)
+	for i := 0; i < 3; i++ {
+		wallets := &Wallets{Wallets: make(map[string]*Wallet)}
+		address := wallets.CreateWallet()
+		signers = append(signers, wallets)
+		pubKeys = append(pubKeys, wallets.Wallets[address].PublicKey)
+	}
+
+	redeemScript, err := MultiSigScript(2, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scriptPubKey := PayToScriptHashScript(HashPubKey(redeemScript))
+	tx, prevTXs := spendingTx(scriptPubKey)
+
+	_, err = NewPartialTransaction(*tx, prevTXs["01"].Vout, nil)
+	assert.NotNil(t, err, "Pay-to-script-hash inputs need their redeem script.")
+
+	redeemScripts := map[string][]byte{hex.EncodeToString(HashPubKey(redeemScript)): redeemScript}
+	unsigned, err := NewPartialTransaction(*tx, prevTXs["01"].Vout, redeemScripts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each signer gets its own copy of the transaction.
+	var copies []*PartialTransaction
+	for _, i := range []int{2, 0} {
+		ptx, err := DeserializePartialTransaction(unsigned.Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+		signed, err := ptx.Sign(signers[i], SigHashAll)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, signed)
+		assert.False(t, ptx.IsComplete(), "One signature out of two.")
+		copies = append(copies, ptx)
+	}
+
+	_, err = copies[0].Finalize()
+	assert.Equal(t, errIncompleteTransaction, err)
+
+	// Signatures already gathered are kept, invalid ones are not added.
+	forged, err := DeserializePartialTransaction(copies[0].Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pubKey, signature := range forged.Inputs[0].Signatures {
+		forged.Inputs[0].Signatures[pubKey] = append([]byte{signature[0] ^ 1}, signature[1:]...)
+	}
+	assert.NotNil(t, copies[1].Combine(forged), "Forged signatures are rejected.")
+	assert.Len(t, copies[1].Inputs[0].Signatures, 1)
+	assert.Nil(t, copies[0].Combine(forged))
+	assert.NotEqual(t, forged.Inputs[0].Signatures, copies[0].Inputs[0].Signatures, "Signatures gathered are kept.")
+
+	assert.Nil(t, copies[0].Combine(copies[1]))
+	assert.True(t, copies[0].IsComplete())
+
+	final, err := copies[0].Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, final.Verify(prevTXs), "Combined signatures unlock the output.")
+
+	other, _ := spendingTx(scriptPubKey)
+	other.Vout[0].Value = 5
+	otherPtx, _ := NewPartialTransaction(*other, prevTXs["01"].Vout, redeemScripts)
+	assert.NotNil(t, copies[0].Combine(otherPtx), "Signatures of another transaction are rejected.")
+}
+
+func TestPartialTransactionWitnessMultiSig(t *testing.T) {
+	var (
+		signers []*Wallets
+		pubKeys [][]byte
+	)
+	for i := 0; i < 2; i++ {
+		wallets := &Wallets{Wallets: make(map[string]*Wallet)}
+		address := wallets.CreateWallet()
+		signers = append(signers, wallets)
+		pubKeys = append(pubKeys, wallets.Wallets[address].PublicKey)
+	}
+
+	witnessScript, err := MultiSigScript(2, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scriptPubKey := PayToWitnessScriptHashScript(WitnessScriptHash(witnessScript))
+	tx, prevTXs := spendingTx(scriptPubKey)
+
+	redeemScripts := map[string][]byte{hex.EncodeToString(HashPubKey(witnessScript)): witnessScript}
+	_, err = NewPartialTransaction(*tx, prevTXs["01"].Vout, redeemScripts)
+	assert.NotNil(t, err, "Witness script hashes are SHA-256 hashes.")
+
+	ptx, err := NewPartialTransaction(*tx, prevTXs["01"].Vout, redeemScriptsByHash([][]byte{witnessScript}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, signer := range signers {
+		signed, err := ptx.Sign(signer, SigHashAll)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, signed)
+	}
+
+	final, err := ptx.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, final.Verify(prevTXs), "Signatures unlock the witness script hash output.")
+}
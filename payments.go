@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var errNoPayments = errors.New("error: no payments")
+
+// Payment is an amount to send to an address.
+type Payment struct {
+	Address string
+	Amount  int
+}
+
+// validatePayments checks that payments are to valid addresses, of positive
+// amounts, and that there is at least one.
+func validatePayments(payments []Payment) error {
+	if len(payments) == 0 {
+		return errNoPayments
+	}
+
+	for _, payment := range payments {
+		if !ValidateAddr(payment.Address) {
+			return fmt.Errorf("error: address %q is not valid", payment.Address)
+		}
+		if payment.Amount <= 0 {
+			return fmt.Errorf("error: amount %d to %s is not positive", payment.Amount, payment.Address)
+		}
+	}
+	return nil
+}
+
+// ParsePaymentList parses payments written as ADDRESS:AMOUNT,...
+func ParsePaymentList(list string) ([]Payment, error) {
+	var payments []Payment
+
+	for _, pair := range strings.Split(list, ",") {
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("payment %q is not ADDRESS:AMOUNT", pair)
+		}
+		amount, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("payment %q has an invalid amount", pair)
+		}
+		payments = append(payments, Payment{parts[0], amount})
+	}
+
+	return payments, validatePayments(payments)
+}
+
+// ParsePaymentsCSV parses payments from CSV records of an address and an
+// amount. A first record of address,amount is taken as a header and
+// skipped, lines starting with # are comments.
+func ParsePaymentsCSV(r io.Reader) ([]Payment, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 && strings.EqualFold(records[0][0], "address") && strings.EqualFold(records[0][1], "amount") {
+		records = records[1:]
+	}
+
+	var payments []Payment
+	for _, record := range records {
+		amount, err := strconv.Atoi(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("payment to %s has an invalid amount %q", record[0], record[1])
+		}
+		payments = append(payments, Payment{record[0], amount})
+	}
+
+	return payments, validatePayments(payments)
+}
+
+// ParsePaymentsJSON parses payments from a JSON object mapping addresses to
+// amounts, as in {"ADDRESS": AMOUNT, ...}. Payments are sorted by address.
+func ParsePaymentsJSON(r io.Reader) ([]Payment, error) {
+	var amounts map[string]int
+	if err := json.NewDecoder(r).Decode(&amounts); err != nil {
+		return nil, err
+	}
+
+	var payments []Payment
+	for address, amount := range amounts {
+		payments = append(payments, Payment{address, amount})
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].Address < payments[j].Address
+	})
+
+	return payments, validatePayments(payments)
+}
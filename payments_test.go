@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePayments(t *testing.T) {
+	first, second := "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM", "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"
+	expected := []Payment{{first, 5}, {second, 7}}
+
+	payments, err := ParsePaymentList(first + ":5," + second + ":7")
+	assert.Nil(t, err)
+	assert.Equal(t, expected, payments)
+
+	payments, err = ParsePaymentsCSV(strings.NewReader("address,amount\n# payouts\n" + first + ",5\n" + second + ", 7\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, payments)
+
+	payments, err = ParsePaymentsJSON(strings.NewReader(`{"` + second + `": 7, "` + first + `": 5}`))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, payments, "Payments are sorted by address.")
+
+	for _, list := range []string{"", first, first + ":", first + ":x", first + ":0", "invalid:5", first + ":5:6"} {
+		_, err := ParsePaymentList(list)
+		assert.NotNil(t, err, "List %q.", list)
+	}
+	for _, records := range []string{"", "address,amount\n", first + "\n", first + ",-1\n", first + ",5,6\n"} {
+		_, err := ParsePaymentsCSV(strings.NewReader(records))
+		assert.NotNil(t, err, "Records %q.", records)
+	}
+	for _, object := range []string{"{}", "[]", `{"` + first + `": 1.5}`} {
+		_, err := ParsePaymentsJSON(strings.NewReader(object))
+		assert.NotNil(t, err, "Object %q.", object)
+	}
+}
+
+func TestNewSendManyTransaction(t *testing.T) {
+	activeNet = &RegTestParams
+	defer func() { activeNet = &MainNetParams }()
+
+	alice := NewWallet()
+	bc := testBlockchain(t, string(alice.GetAddress()))
+	UTXOSet := UTXOSet{bc}
+	reward := bc.Iterator().Next().Transactions[0]
+
+	payments := []Payment{
+		{string(NewWallet().GetAddress()), 3},
+		{string(NewWallet().GetAddress()), 1},
+		{string(NewWallet().GetAddress()), 2},
+	}
+	tx := NewSendManyTransaction(alice, payments, 0, &CoinControl{FeeRate: 7}, &UTXOSet)
+
+	if assert.Len(t, tx.Vin, 1) {
+		assert.Equal(t, reward.ID, tx.Vin[0].Txid, "The genesis reward funds the payments.")
+	}
+	if assert.Len(t, tx.Vout, len(payments)+1) {
+		for i, payment := range payments {
+			assert.Equal(t, *NewTXOutput(payment.Amount, payment.Address), tx.Vout[i], "Payment %d.", i)
+		}
+		change := tx.Vout[len(payments)]
+		assert.Equal(t, PayToPubKeyHashScript(HashPubKey(alice.PublicKey)), change.ScriptPubKey, "Change goes back to the Base58 address.")
+		fee := activeNet.Subsidy - 6 - change.Value
+		assert.True(t, fee > 0)
+	}
+
+	assert.Equal(t, tx.ComputeID(), tx.ID, "The ID is computed once signed.")
+	assert.True(t, bc.VerifyTransaction(tx))
+}
@@ -11,11 +11,6 @@ import (
 // Global limiter for avoiding nonce increment overflow.
 var maxNonce = math.MaxInt64
 
-// Difficulty of mining.
-// In Bitcoin, “target bits” is the block header storing the difficulty at which the block was mined. We won’t implement a target adjusting algorithm, for now, so we can just define the difficulty as a global constant.
-// 24 is an arbitrary number.
-const targetBits = 16
-
 // ProofOfWork represents proof-of-work.
 type ProofOfWork struct {
 	block  *Block
@@ -23,9 +18,10 @@ type ProofOfWork struct {
 }
 
 // NewProofOfWork builds and returns a ProofOfWork
+// In Bitcoin, “target bits” is the block header storing the difficulty at which the block was mined. We won’t implement a target adjusting algorithm, for now, so the difficulty is a fixed parameter of the network.
 func NewProofOfWork(b *Block) *ProofOfWork {
 	target := big.NewInt(1)
-	target.Lsh(target, uint(256-targetBits))
+	target.Lsh(target, uint(256-activeNet.TargetBits))
 
 	pow := &ProofOfWork{b, target}
 
@@ -40,8 +36,9 @@ func (pow *ProofOfWork) prepareData(nonce int) []byte {
 		[][]byte{
 			pow.block.PrevBlockHash,
 			pow.block.HashTransactions(),
+			pow.block.HashWitnesses(),
 			IntToHex(pow.block.Timestamp),
-			IntToHex(int64(targetBits)),
+			IntToHex(int64(activeNet.TargetBits)),
 			IntToHex(int64(nonce)),
 		},
 		[]byte{},
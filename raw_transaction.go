@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// NewRawTransaction builds a PartialTransaction spending the unspent outputs
+// of inputs to payments, for review and signing away from the blockchain.
+// Whatever inputs are worth beyond payments is left as fee. redeemScripts
+// holds the redeem scripts of inputs paying to a script hash. An outpoint may
+// only be given once.
+func NewRawTransaction(inputs []OutPoint, payments []Payment, lockTime uint32, redeemScripts [][]byte, UTXOSet *UTXOSet) (*PartialTransaction, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("error: no inputs")
+	}
+	if err := validatePayments(payments); err != nil {
+		return nil, err
+	}
+
+	// Inputs have to be non-final for the lock time to apply.
+	sequence := uint32(sequenceFinal)
+	if lockTime != 0 {
+		sequence = sequenceLockTimeEnabled
+	}
+
+	tx := Transaction{LockTime: lockTime}
+	var prevOuts []TXOutput
+	value := 0
+	spent := make(map[string]bool)
+	for _, outPoint := range inputs {
+		if spent[outPoint.String()] {
+			return nil, fmt.Errorf("error: %s is spent twice", outPoint)
+		}
+		spent[outPoint.String()] = true
+
+		coin, ok := UTXOSet.FindCoin(outPoint)
+		if !ok {
+			return nil, fmt.Errorf("error: %s is not an unspent output", outPoint)
+		}
+		tx.Vin = append(tx.Vin, TXInput{Txid: coin.Txid, Vout: coin.Vout, Sequence: sequence})
+		prevOuts = append(prevOuts, coin.Output)
+		value += coin.Output.Value
+	}
+
+	for _, payment := range payments {
+		tx.Vout = append(tx.Vout, *NewTXOutput(payment.Amount, payment.Address))
+		value -= payment.Amount
+	}
+	if value < 0 {
+		return nil, errInsufficientFunds
+	}
+	tx.ID = tx.ComputeID()
+
+	return NewPartialTransaction(tx, prevOuts, redeemScriptsByHash(redeemScripts))
+}
+
+// NewUnsignedTransaction builds a PartialTransaction paying payments out of
+// the coins of from, an address paying to a public key hash, with change
+// back to from. Like the signed transactions of send, it spends the outputs
+// paying to both the Base58 and the Bech32 address of the key. It needs no key of from, the transaction is signed where the key is
+// kept with PartialTransaction.Sign, which needs no blockchain.
+func NewUnsignedTransaction(from string, payments []Payment, lockTime uint32, control *CoinControl, UTXOSet *UTXOSet) (*PartialTransaction, error) {
+	lockScript, err := PayToAddrScript(from)
+	if err != nil {
+		return nil, err
+	}
+	pubKeyHash := extractPubKeyHash(scriptCode(lockScript))
+	if pubKeyHash == nil {
+		return nil, fmt.Errorf("error: %s does not pay to a public key hash", from)
+	}
+
+	coins := UTXOSet.FindCoins(pubKeyHashLockScripts(pubKeyHash)...)
+	tx := newPaymentTransaction(coins, payments, lockTime, control, func() string { return from })
+
+	// Embed the outputs spent, the signer cannot look them up.
+	prevOuts := make(map[string]TXOutput)
+	for _, coin := range coins {
+		prevOuts[coin.OutPoint.String()] = coin.Output
+	}
+	var spent []TXOutput
+	for _, vin := range tx.Vin {
+		spent = append(spent, prevOuts[OutPoint{vin.Txid, vin.Vout}.String()])
+	}
+
+	return NewPartialTransaction(*tx, spent, nil)
+}
+
+// txJSON is the JSON form of a transaction, with the data shown by
+// Transaction.String.
+type txJSON struct {
+	TxID     string       `json:"txid"`
+	WTxID    string       `json:"wtxid"`
+	Vin      []txInJSON   `json:"vin"`
+	Vout     []txOutJSON  `json:"vout"`
+	LockTime uint32       `json:"locktime"`
+	Fee      *int         `json:"fee,omitempty"`
+	Complete *bool        `json:"complete,omitempty"`
+	Partial  []txPartJSON `json:"partial,omitempty"`
+}
+
+type txInJSON struct {
+	TxID      string `json:"txid"`
+	Vout      int    `json:"vout"`
+	ScriptSig string `json:"scriptSig"`
+	Sequence  uint32 `json:"sequence"`
+}
+
+type txOutJSON struct {
+	Value        int    `json:"value"`
+	ScriptPubKey string `json:"scriptPubKey"`
+	Type         string `json:"type"`
+	Address      string `json:"address,omitempty"`
+}
+
+// txPartJSON is the signing state of an input of a PartialTransaction.
+type txPartJSON struct {
+	PrevOut      txOutJSON `json:"prevout"`
+	RedeemScript string    `json:"redeemScript,omitempty"`
+	// SignedBy lists the hex-encoded public keys that signed the input.
+	SignedBy []string `json:"signedBy"`
+}
+
+func newTxOutJSON(out TXOutput) txOutJSON {
+	return txOutJSON{
+		Value:        out.Value,
+		ScriptPubKey: DisasmScript(out.ScriptPubKey),
+		Type:         GetOutputType(out.ScriptPubKey).String(),
+		Address:      ExtractAddress(out.ScriptPubKey),
+	}
+}
+
+func newTxJSON(tx *Transaction) txJSON {
+	decoded := txJSON{
+		TxID:     hex.EncodeToString(tx.ID),
+		WTxID:    hex.EncodeToString(tx.WitnessID()),
+		Vin:      []txInJSON{},
+		Vout:     []txOutJSON{},
+		LockTime: tx.LockTime,
+	}
+
+	for _, input := range tx.Vin {
+		decoded.Vin = append(decoded.Vin, txInJSON{
+			TxID:      hex.EncodeToString(input.Txid),
+			Vout:      input.Vout,
+			ScriptSig: DisasmScript(input.ScriptSig),
+			Sequence:  input.Sequence,
+		})
+	}
+	for _, output := range tx.Vout {
+		decoded.Vout = append(decoded.Vout, newTxOutJSON(output))
+	}
+
+	return decoded
+}
+
+// MarshalJSON encodes the transaction as JSON, with scripts disassembled.
+func (tx Transaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newTxJSON(&tx))
+}
+
+// MarshalJSON encodes the partial transaction as JSON: its transaction,
+// along with the outputs spent, the signatures gathered and the fee.
+func (ptx PartialTransaction) MarshalJSON() ([]byte, error) {
+	decoded := newTxJSON(&ptx.Tx)
+
+	fee := 0
+	for _, input := range ptx.Inputs {
+		fee += input.PrevOut.Value
+	}
+	for _, output := range ptx.Tx.Vout {
+		fee -= output.Value
+	}
+	complete := ptx.IsComplete()
+	decoded.Fee, decoded.Complete = &fee, &complete
+
+	for _, input := range ptx.Inputs {
+		part := txPartJSON{PrevOut: newTxOutJSON(input.PrevOut), SignedBy: []string{}}
+		if input.RedeemScript != nil {
+			part.RedeemScript = DisasmScript(input.RedeemScript)
+		}
+		for pubKey := range input.Signatures {
+			part.SignedBy = append(part.SignedBy, pubKey)
+		}
+		sort.Strings(part.SignedBy)
+		decoded.Partial = append(decoded.Partial, part)
+	}
+
+	return json.Marshal(decoded)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+// testUTXOSet returns an empty UTXO set stored in a temporary database.
+func testUTXOSet(t *testing.T) UTXOSet {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "chainstate.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket([]byte(utxoBucket))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return UTXOSet{&Blockchain{db: db}}
+}
+
+func TestRawTransaction(t *testing.T) {
+	UTXOSet := testUTXOSet(t)
+
+	wallet := NewWallet()
+	wallets := &Wallets{Wallets: map[string]*Wallet{}}
+	from := wallets.addWallet(wallet)
+	to := string(NewWallet().GetAddress())
+	coinbase := NewCoinbaseTX(from, "Block 1")
+	UTXOSet.Update(&Block{Hash: []byte{0xb1}, Height: 1, Transactions: []*Transaction{coinbase}})
+	outPoint := OutPoint{coinbase.ID, 0}
+
+	_, err := NewRawTransaction([]OutPoint{outPoint}, []Payment{{to, 11}}, 0, nil, &UTXOSet)
+	assert.Equal(t, errInsufficientFunds, err)
+	_, err = NewRawTransaction([]OutPoint{{coinbase.ID, 1}}, []Payment{{to, 1}}, 0, nil, &UTXOSet)
+	assert.NotNil(t, err, "Output 1 does not exist.")
+	_, err = NewRawTransaction([]OutPoint{outPoint, outPoint}, []Payment{{to, 15}}, 0, nil, &UTXOSet)
+	assert.NotNil(t, err, "An output is spent once.")
+
+	ptx, err := NewRawTransaction([]OutPoint{outPoint}, []Payment{{to, 6}, {from, 3}}, 0, nil, &UTXOSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded txJSON
+	data, err := json.Marshal(ptx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, hex.EncodeToString(ptx.Tx.ID), decoded.TxID)
+	assert.Equal(t, hex.EncodeToString(coinbase.ID), decoded.Vin[0].TxID)
+	assert.Equal(t, to, decoded.Vout[0].Address)
+	assert.Equal(t, 3, decoded.Vout[1].Value)
+	assert.Equal(t, 1, *decoded.Fee)
+	assert.False(t, *decoded.Complete)
+	assert.Equal(t, from, decoded.Partial[0].PrevOut.Address)
+	assert.Empty(t, decoded.Partial[0].SignedBy)
+
+	// Signing needs the wallet file only.
+	signed, err := ptx.Sign(wallets, SigHashAll)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, signed)
+	tx, err := ptx.Finalize()
+	assert.Nil(t, err)
+	assert.Equal(t, ptx.Tx.ID, tx.ID)
+	assert.True(t, tx.Verify(map[string]Transaction{hex.EncodeToString(coinbase.ID): *coinbase}))
+
+	data, err = json.Marshal(ptx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.True(t, *decoded.Complete)
+	assert.Equal(t, []string{hex.EncodeToString(wallet.PublicKey)}, decoded.Partial[0].SignedBy)
+}
@@ -0,0 +1,560 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Opcodes understood by the script interpreter. Their values match Bitcoin.
+const (
+	OP_0                   = 0x00
+	OP_DATA_1              = 0x01
+	OP_DATA_75             = 0x4b
+	OP_PUSHDATA1           = 0x4c
+	OP_PUSHDATA2           = 0x4d
+	OP_1NEGATE             = 0x4f
+	OP_RESERVED            = 0x50
+	OP_1                   = 0x51
+	OP_16                  = 0x60
+	OP_VERIFY              = 0x69
+	OP_RETURN              = 0x6a
+	OP_DROP                = 0x75
+	OP_DUP                 = 0x76
+	OP_EQUAL               = 0x87
+	OP_EQUALVERIFY         = 0x88
+	OP_HASH160             = 0xa9
+	OP_CHECKSIG            = 0xac
+	OP_CHECKMULTISIG       = 0xae
+	OP_CHECKLOCKTIMEVERIFY = 0xb1
+	OP_CHECKSEQUENCEVERIFY = 0xb2
+)
+
+var opcodeNames = map[byte]string{
+	OP_0:                   "OP_0",
+	OP_PUSHDATA1:           "OP_PUSHDATA1",
+	OP_PUSHDATA2:           "OP_PUSHDATA2",
+	OP_1NEGATE:             "OP_1NEGATE",
+	OP_VERIFY:              "OP_VERIFY",
+	OP_RETURN:              "OP_RETURN",
+	OP_DROP:                "OP_DROP",
+	OP_DUP:                 "OP_DUP",
+	OP_EQUAL:               "OP_EQUAL",
+	OP_EQUALVERIFY:         "OP_EQUALVERIFY",
+	OP_HASH160:             "OP_HASH160",
+	OP_CHECKSIG:            "OP_CHECKSIG",
+	OP_CHECKMULTISIG:       "OP_CHECKMULTISIG",
+	OP_CHECKLOCKTIMEVERIFY: "OP_CHECKLOCKTIMEVERIFY",
+	OP_CHECKSEQUENCEVERIFY: "OP_CHECKSEQUENCEVERIFY",
+}
+
+var errMalformedScript = errors.New("malformed script")
+
+// parsedOp is an opcode of a script along with the data it pushes.
+type parsedOp struct {
+	opcode byte
+	data   []byte
+}
+
+// isPush checks whether the opcode only pushes data on the stack.
+func (op parsedOp) isPush() bool {
+	return op.opcode <= OP_16 && op.opcode != OP_RESERVED
+}
+
+// pushValue returns the element pushed by a push opcode.
+func (op parsedOp) pushValue() []byte {
+	switch {
+	case op.opcode == OP_1NEGATE:
+		return scriptNum(-1).Bytes()
+	case op.opcode >= OP_1 && op.opcode <= OP_16:
+		return scriptNum(op.opcode - OP_1 + 1).Bytes()
+	}
+	return op.data
+}
+
+// parseScript splits a script into its opcodes.
+func parseScript(script []byte) ([]parsedOp, error) {
+	var ops []parsedOp
+
+	for i := 0; i < len(script); {
+		op := parsedOp{opcode: script[i]}
+		i++
+
+		var dataLen int
+		switch {
+		case op.opcode >= OP_DATA_1 && op.opcode <= OP_DATA_75:
+			dataLen = int(op.opcode)
+		case op.opcode == OP_PUSHDATA1:
+			if i+1 > len(script) {
+				return nil, errMalformedScript
+			}
+			dataLen = int(script[i])
+			i++
+		case op.opcode == OP_PUSHDATA2:
+			if i+2 > len(script) {
+				return nil, errMalformedScript
+			}
+			dataLen = int(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		}
+
+		if i+dataLen > len(script) {
+			return nil, errMalformedScript
+		}
+		op.data = script[i : i+dataLen]
+		i += dataLen
+
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
+
+// DisasmScript returns a human-readable representation of a script.
+func DisasmScript(script []byte) string {
+	ops, err := parseScript(script)
+	if err != nil {
+		return fmt.Sprintf("[error] %x", script)
+	}
+
+	var parts []string
+	for _, op := range ops {
+		switch {
+		case op.opcode >= OP_DATA_1 && op.opcode <= OP_PUSHDATA2:
+			parts = append(parts, hex.EncodeToString(op.data))
+		case op.opcode >= OP_1 && op.opcode <= OP_16:
+			parts = append(parts, fmt.Sprintf("OP_%d", op.opcode-OP_1+1))
+		case opcodeNames[op.opcode] != "":
+			parts = append(parts, opcodeNames[op.opcode])
+		default:
+			parts = append(parts, fmt.Sprintf("OP_UNKNOWN%d", op.opcode))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// ScriptBuilder builds scripts one opcode at a time.
+type ScriptBuilder struct {
+	script []byte
+}
+
+// NewScriptBuilder returns an empty ScriptBuilder.
+func NewScriptBuilder() *ScriptBuilder {
+	return &ScriptBuilder{}
+}
+
+// AddOp appends an opcode.
+func (b *ScriptBuilder) AddOp(opcode byte) *ScriptBuilder {
+	b.script = append(b.script, opcode)
+	return b
+}
+
+// AddData appends the shortest push of data.
+func (b *ScriptBuilder) AddData(data []byte) *ScriptBuilder {
+	switch {
+	case len(data) <= OP_DATA_75:
+		b.script = append(b.script, byte(len(data)))
+	case len(data) <= 0xff:
+		b.script = append(b.script, OP_PUSHDATA1, byte(len(data)))
+	default:
+		b.script = append(b.script, OP_PUSHDATA2)
+		b.script = binary.LittleEndian.AppendUint16(b.script, uint16(len(data)))
+	}
+	b.script = append(b.script, data...)
+
+	return b
+}
+
+// AddInt64 appends the shortest push of a number.
+func (b *ScriptBuilder) AddInt64(n int64) *ScriptBuilder {
+	switch {
+	case n == 0:
+		return b.AddOp(OP_0)
+	case n == -1:
+		return b.AddOp(OP_1NEGATE)
+	case n >= 1 && n <= 16:
+		return b.AddOp(byte(OP_1 - 1 + n))
+	}
+	return b.AddData(scriptNum(n).Bytes())
+}
+
+// Script returns the built script.
+func (b *ScriptBuilder) Script() []byte {
+	return b.script
+}
+
+// PayToPubKeyHashScript returns a script locking an output to the owner of
+// the public key hashing to pubKeyHash.
+func PayToPubKeyHashScript(pubKeyHash []byte) []byte {
+	return NewScriptBuilder().
+		AddOp(OP_DUP).
+		AddOp(OP_HASH160).
+		AddData(pubKeyHash).
+		AddOp(OP_EQUALVERIFY).
+		AddOp(OP_CHECKSIG).
+		Script()
+}
+
+// extractPubKeyHash returns the public key hash a pay-to-pubkey-hash script
+// is locked to, or nil for other scripts.
+func extractPubKeyHash(script []byte) []byte {
+	if len(script) == 25 &&
+		script[0] == OP_DUP &&
+		script[1] == OP_HASH160 &&
+		script[2] == 20 &&
+		script[23] == OP_EQUALVERIFY &&
+		script[24] == OP_CHECKSIG {
+		return script[3:23]
+	}
+	return nil
+}
+
+// PayToScriptHashScript returns a script locking an output to the redeem
+// script hashing to scriptHash. The spender reveals the redeem script as the
+// last push of the signature script, followed by what it needs to succeed.
+func PayToScriptHashScript(scriptHash []byte) []byte {
+	return NewScriptBuilder().
+		AddOp(OP_HASH160).
+		AddData(scriptHash).
+		AddOp(OP_EQUAL).
+		Script()
+}
+
+// extractScriptHash returns the script hash a pay-to-script-hash script is
+// locked to, or nil for other scripts.
+func extractScriptHash(script []byte) []byte {
+	if len(script) == 23 &&
+		script[0] == OP_HASH160 &&
+		script[1] == 20 &&
+		script[22] == OP_EQUAL {
+		return script[2:22]
+	}
+	return nil
+}
+
+// witnessScriptHashLen is the length of the hash of a script a version 0
+// witness program pays to.
+const witnessScriptHashLen = sha256.Size
+
+// PayToWitnessPubKeyHashScript returns a version 0 witness program locking
+// an output to the owner of the public key hashing to pubKeyHash. It is
+// spent like a pay-to-pubkey-hash output.
+func PayToWitnessPubKeyHashScript(pubKeyHash []byte) []byte {
+	return NewScriptBuilder().AddOp(OP_0).AddData(pubKeyHash).Script()
+}
+
+// PayToWitnessScriptHashScript returns a version 0 witness program locking
+// an output to the witness script hashing to scriptHash with WitnessScriptHash.
+// It is spent like a pay-to-script-hash output.
+func PayToWitnessScriptHashScript(scriptHash []byte) []byte {
+	return NewScriptBuilder().AddOp(OP_0).AddData(scriptHash).Script()
+}
+
+// WitnessScriptHash returns the hash of script a version 0 witness program
+// pays to, its SHA-256.
+func WitnessScriptHash(script []byte) []byte {
+	hash := sha256.Sum256(script)
+	return hash[:]
+}
+
+// extractWitnessProgram returns the version and the program of a witness
+// program: a small number followed by a push of 2 to 40 bytes. ok is false
+// for other scripts.
+func extractWitnessProgram(script []byte) (version byte, program []byte, ok bool) {
+	if len(script) < 4 || len(script) > 42 || int(script[1]) != len(script)-2 {
+		return 0, nil, false
+	}
+
+	switch {
+	case script[0] == OP_0:
+		version = 0
+	case script[0] >= OP_1 && script[0] <= OP_16:
+		version = script[0] - OP_1 + 1
+	default:
+		return 0, nil, false
+	}
+
+	return version, script[2:], true
+}
+
+// extractWitnessPubKeyHash returns the public key hash a version 0 witness
+// program is locked to, or nil for other scripts.
+func extractWitnessPubKeyHash(script []byte) []byte {
+	if version, program, ok := extractWitnessProgram(script); ok && version == 0 && len(program) == addressHashLen {
+		return program
+	}
+	return nil
+}
+
+// extractWitnessScriptHash returns the script hash a version 0 witness
+// program is locked to, or nil for other scripts.
+func extractWitnessScriptHash(script []byte) []byte {
+	if version, program, ok := extractWitnessProgram(script); ok && version == 0 && len(program) == witnessScriptHashLen {
+		return program
+	}
+	return nil
+}
+
+// scriptCode returns the script run to spend an output locked by script, and
+// that signatures commit to: the pay-to-pubkey-hash script of the key of a
+// version 0 witness public key hash, script itself otherwise.
+func scriptCode(script []byte) []byte {
+	if pubKeyHash := extractWitnessPubKeyHash(script); pubKeyHash != nil {
+		return PayToPubKeyHashScript(pubKeyHash)
+	}
+	return script
+}
+
+// MultiSigScript returns a script requiring nRequired signatures matching
+// pubKeys, given in the order of the keys.
+func MultiSigScript(nRequired int, pubKeys [][]byte) ([]byte, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > maxPubKeysPerMultiSig {
+		return nil, fmt.Errorf("multisig needs 1 to %d public keys, got %d", maxPubKeysPerMultiSig, len(pubKeys))
+	}
+	if nRequired < 1 || nRequired > len(pubKeys) {
+		return nil, fmt.Errorf("cannot require %d signatures out of %d", nRequired, len(pubKeys))
+	}
+
+	builder := NewScriptBuilder().AddInt64(int64(nRequired))
+	for _, pubKey := range pubKeys {
+		if len(pubKey) != pubKeyLen {
+			return nil, errInvalidPubKey
+		}
+		builder.AddData(pubKey)
+	}
+
+	return builder.AddInt64(int64(len(pubKeys))).AddOp(OP_CHECKMULTISIG).Script(), nil
+}
+
+// extractMultiSig returns the number of signatures required by a script built
+// by MultiSigScript and its public keys. ok is false for other scripts.
+func extractMultiSig(script []byte) (nRequired int, pubKeys [][]byte, ok bool) {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) < 4 || ops[len(ops)-1].opcode != OP_CHECKMULTISIG {
+		return 0, nil, false
+	}
+
+	small := func(op parsedOp) int {
+		if op.opcode < OP_1 || op.opcode > OP_16 {
+			return -1
+		}
+		return int(op.opcode-OP_1) + 1
+	}
+
+	nRequired = small(ops[0])
+	nKeys := small(ops[len(ops)-2])
+	if nRequired < 1 || nKeys < nRequired || nKeys != len(ops)-3 {
+		return 0, nil, false
+	}
+	for _, op := range ops[1 : len(ops)-2] {
+		if len(op.data) != pubKeyLen {
+			return 0, nil, false
+		}
+		pubKeys = append(pubKeys, op.data)
+	}
+
+	return nRequired, pubKeys, true
+}
+
+// maxDataCarrierSize is the number of bytes a data output may carry.
+const maxDataCarrierSize = 80
+
+// NullDataScript returns the script of a provably unspendable output carrying
+// data, such as the hash of a document to timestamp.
+func NullDataScript(data []byte) ([]byte, error) {
+	if len(data) > maxDataCarrierSize {
+		return nil, fmt.Errorf("data of %d bytes exceeds %d bytes", len(data), maxDataCarrierSize)
+	}
+	return NewScriptBuilder().AddOp(OP_RETURN).AddData(data).Script(), nil
+}
+
+// extractNullData returns the data carried by a script built by
+// NullDataScript. ok is false for other scripts.
+func extractNullData(script []byte) (data []byte, ok bool) {
+	ops, err := parseScript(script)
+	if err != nil || len(ops) != 2 || ops[0].opcode != OP_RETURN || !ops[1].isPush() {
+		return nil, false
+	}
+	data = ops[1].pushValue()
+	if len(data) > maxDataCarrierSize {
+		return nil, false
+	}
+	return data, true
+}
+
+// isUnspendable checks whether no input can ever spend an output locked by
+// script, so that it need not be tracked as unspent.
+func isUnspendable(script []byte) bool {
+	return len(script) > 0 && script[0] == OP_RETURN
+}
+
+// OutputType is the kind of locking script of an output, telling how it is
+// spent.
+type OutputType int
+
+// Output types.
+const (
+	NonStandardTy OutputType = iota
+	PubKeyHashTy
+	ScriptHashTy
+	WitnessV0PubKeyHashTy
+	WitnessV0ScriptHashTy
+	WitnessUnknownTy
+	MultiSigTy
+	NullDataTy
+)
+
+var outputTypeNames = map[OutputType]string{
+	NonStandardTy:         "nonstandard",
+	PubKeyHashTy:          "pubkeyhash",
+	ScriptHashTy:          "scripthash",
+	WitnessV0PubKeyHashTy: "witness_v0_keyhash",
+	WitnessV0ScriptHashTy: "witness_v0_scripthash",
+	WitnessUnknownTy:      "witness_unknown",
+	MultiSigTy:            "multisig",
+	NullDataTy:            "nulldata",
+}
+
+func (t OutputType) String() string {
+	if name, ok := outputTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("OutputType(%d)", int(t))
+}
+
+// GetOutputType returns the type of the locking script of an output.
+func GetOutputType(script []byte) OutputType {
+	switch {
+	case extractPubKeyHash(script) != nil:
+		return PubKeyHashTy
+	case extractScriptHash(script) != nil:
+		return ScriptHashTy
+	case extractWitnessPubKeyHash(script) != nil:
+		return WitnessV0PubKeyHashTy
+	case extractWitnessScriptHash(script) != nil:
+		return WitnessV0ScriptHashTy
+	}
+	if version, _, ok := extractWitnessProgram(script); ok && version > 0 {
+		return WitnessUnknownTy
+	}
+	if _, _, ok := extractMultiSig(script); ok {
+		return MultiSigTy
+	}
+	if _, ok := extractNullData(script); ok {
+		return NullDataTy
+	}
+	return NonStandardTy
+}
+
+// scriptNum is a number as encoded on the stack: little endian with the sign
+// in the most significant bit.
+type scriptNum int64
+
+// maxScriptNumLen is the length of the numbers accepted by opcodes. Five
+// bytes fit lock times up to 2^39.
+const maxScriptNumLen = 5
+
+// Bytes returns the minimal encoding of the number.
+func (n scriptNum) Bytes() []byte {
+	if n == 0 {
+		return nil
+	}
+
+	negative := n < 0
+	abs := int64(n)
+	if negative {
+		abs = -abs
+	}
+
+	var result []byte
+	for abs > 0 {
+		result = append(result, byte(abs&0xff))
+		abs >>= 8
+	}
+
+	if result[len(result)-1]&0x80 != 0 {
+		extra := byte(0x00)
+		if negative {
+			extra = 0x80
+		}
+		result = append(result, extra)
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+
+	return result
+}
+
+// makeScriptNum decodes a number from the stack.
+func makeScriptNum(b []byte, maxLen int) (scriptNum, error) {
+	if len(b) > maxLen {
+		return 0, fmt.Errorf("number of %d bytes is too long", len(b))
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	// Reject non-minimal encodings, they would make the same number
+	// malleable.
+	if b[len(b)-1]&0x7f == 0 && (len(b) == 1 || b[len(b)-2]&0x80 == 0) {
+		return 0, errors.New("number is not minimally encoded")
+	}
+
+	var n int64
+	for i, v := range b {
+		n |= int64(v) << uint(8*i)
+	}
+
+	if b[len(b)-1]&0x80 != 0 {
+		n &= ^(int64(0x80) << uint(8*(len(b)-1)))
+		return scriptNum(-n), nil
+	}
+
+	return scriptNum(n), nil
+}
+
+// castToBool interprets a stack element as a boolean. Zero and negative zero
+// are false, anything else is true.
+func castToBool(b []byte) bool {
+	for i, v := range b {
+		if v != 0 {
+			return !(i == len(b)-1 && v == 0x80)
+		}
+	}
+	return false
+}
+
+// isPushOnly checks whether a script only pushes data.
+func isPushOnly(script []byte) bool {
+	ops, err := parseScript(script)
+	if err != nil {
+		return false
+	}
+	for _, op := range ops {
+		if !op.isPush() {
+			return false
+		}
+	}
+	return true
+}
+
+// pushedData returns the data pushed by a push-only script.
+func pushedData(script []byte) ([][]byte, error) {
+	ops, err := parseScript(script)
+	if err != nil {
+		return nil, err
+	}
+
+	var data [][]byte
+	for _, op := range ops {
+		if !op.isPush() {
+			return nil, errors.New("script is not push only")
+		}
+		data = append(data, op.pushValue())
+	}
+
+	return data, nil
+}
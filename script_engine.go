@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const (
+	maxScriptSize         = 10000
+	maxStackSize          = 1000
+	maxPubKeysPerMultiSig = 20
+	// lockTimeThreshold separates lock times given as block heights, below
+	// it, from lock times given as Unix timestamps.
+	lockTimeThreshold = 500000000
+)
+
+var (
+	errEmptyStack  = errors.New("operation on an empty stack")
+	errEvalFalse   = errors.New("script evaluated to false")
+	errVerifyFalse = errors.New("verification failed")
+)
+
+// VerifyScript checks that scriptSig unlocks scriptPubKey, the locking
+// script of the output worth amount spent by input inIdx of tx.
+// The signature script may only push data. It is run first and the locking
+// script then runs on the resulting stack, which must end with a true
+// element.
+// When the locking script pays to a script hash, the last element pushed by
+// the signature script is the redeem script. It then runs on the rest of the
+// elements and must succeed as well.
+// Transactions carry no witnesses apart from signature scripts, so a version
+// 0 witness program is unlocked by the signature script: a public key hash
+// program as a pay-to-pubkey-hash output, a script hash program as a
+// pay-to-script-hash output whose redeem script hashes to the program with
+// WitnessScriptHash. Nodes without this rule reject such spends, so it is a
+// consensus change. Programs of later versions are not interpreted.
+func VerifyScript(scriptSig, scriptPubKey []byte, tx *Transaction, inIdx, amount int) error {
+	return verifyScript(scriptSig, scriptPubKey, tx, inIdx, amount, NewTxSigHashes(tx))
+}
+
+// verifyScript is VerifyScript with the signature hashes of tx computed
+// beforehand, so that they are shared by the inputs.
+func verifyScript(scriptSig, scriptPubKey []byte, tx *Transaction, inIdx, amount int, hashes *TxSigHashes) error {
+	if !isPushOnly(scriptSig) {
+		return errors.New("signature script is not push only")
+	}
+
+	if version, program, ok := extractWitnessProgram(scriptPubKey); ok && version == 0 && len(program) != addressHashLen && len(program) != witnessScriptHashLen {
+		return fmt.Errorf("invalid version 0 witness program length %d", len(program))
+	}
+	witnessScriptHash := extractWitnessScriptHash(scriptPubKey)
+	scriptPubKey = scriptCode(scriptPubKey)
+
+	vm := &engine{tx: tx, inIdx: inIdx, amount: amount, hashes: hashes}
+	if err := vm.execute(scriptSig); err != nil {
+		return err
+	}
+	pushed := append([][]byte(nil), vm.stack...)
+
+	if witnessScriptHash != nil {
+		if len(pushed) == 0 {
+			return errEmptyStack
+		}
+		witnessScript := pushed[len(pushed)-1]
+		if !bytes.Equal(WitnessScriptHash(witnessScript), witnessScriptHash) {
+			return errors.New("witness script does not match the witness program")
+		}
+		vm.stack = pushed[:len(pushed)-1]
+		if err := vm.execute(witnessScript); err != nil {
+			return err
+		}
+		return vm.checkResult()
+	}
+
+	if err := vm.execute(scriptPubKey); err != nil {
+		return err
+	}
+	if err := vm.checkResult(); err != nil {
+		return err
+	}
+
+	if extractScriptHash(scriptPubKey) == nil {
+		return nil
+	}
+	if len(pushed) == 0 {
+		return errEmptyStack
+	}
+	redeemScript := pushed[len(pushed)-1]
+	vm.stack = pushed[:len(pushed)-1]
+	if err := vm.execute(redeemScript); err != nil {
+		return err
+	}
+
+	return vm.checkResult()
+}
+
+// engine is a stack machine running the scripts of a transaction input.
+type engine struct {
+	tx     *Transaction
+	inIdx  int
+	amount int
+	hashes *TxSigHashes
+	stack  [][]byte
+	// script is the script being run. Signatures commit to it.
+	script []byte
+}
+
+func (vm *engine) execute(script []byte) error {
+	if len(script) > maxScriptSize {
+		return fmt.Errorf("script of %d bytes is too long", len(script))
+	}
+	ops, err := parseScript(script)
+	if err != nil {
+		return err
+	}
+	vm.script = script
+
+	for _, op := range ops {
+		if err := vm.step(op); err != nil {
+			return err
+		}
+		if len(vm.stack) > maxStackSize {
+			return errors.New("stack is too large")
+		}
+	}
+
+	return nil
+}
+
+// checkResult fails unless the stack ends with a true element.
+func (vm *engine) checkResult() error {
+	if len(vm.stack) == 0 || !castToBool(vm.stack[len(vm.stack)-1]) {
+		return errEvalFalse
+	}
+	return nil
+}
+
+func (vm *engine) step(op parsedOp) error {
+	if op.isPush() {
+		vm.push(op.pushValue())
+		return nil
+	}
+
+	switch op.opcode {
+	case OP_VERIFY:
+		v, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		if !castToBool(v) {
+			return errVerifyFalse
+		}
+
+	case OP_RETURN:
+		return errors.New("output is unspendable")
+
+	case OP_DROP:
+		_, err := vm.pop()
+		return err
+
+	case OP_DUP:
+		v, err := vm.peek()
+		if err != nil {
+			return err
+		}
+		vm.push(v)
+
+	case OP_EQUAL, OP_EQUALVERIFY:
+		a, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		if op.opcode == OP_EQUALVERIFY {
+			if !bytes.Equal(a, b) {
+				return errVerifyFalse
+			}
+			return nil
+		}
+		vm.pushBool(bytes.Equal(a, b))
+
+	case OP_HASH160:
+		v, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.push(HashPubKey(v))
+
+	case OP_CHECKSIG:
+		pubKey, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		signature, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		vm.pushBool(vm.checkSig(signature, pubKey))
+
+	case OP_CHECKMULTISIG:
+		return vm.checkMultiSig()
+
+	case OP_CHECKLOCKTIMEVERIFY:
+		return vm.checkLockTimeVerify()
+
+	case OP_CHECKSEQUENCEVERIFY:
+		return vm.checkSequenceVerify()
+
+	default:
+		return fmt.Errorf("unknown opcode 0x%02x", op.opcode)
+	}
+
+	return nil
+}
+
+// checkSig checks signature, followed by its hash type, against the hash of
+// the transaction committing to the running script.
+func (vm *engine) checkSig(signature, pubKey []byte) bool {
+	if len(signature) != signatureLen+1 {
+		return false
+	}
+	hashType := SigHashType(signature[signatureLen])
+
+	hash, err := vm.tx.signatureHash(vm.hashes, vm.inIdx, vm.script, vm.amount, hashType)
+	if err != nil {
+		return false
+	}
+	return activeNet.Curve.Verify(pubKey, hash, signature[:signatureLen])
+}
+
+// checkMultiSig pops N public keys and M signatures and checks that every
+// signature matches one of the keys, in the same order. Unlike Bitcoin, no
+// extra element is consumed.
+//
+//	Stack: <sig1> ... <sigM> M <pubKey1> ... <pubKeyN> N
+func (vm *engine) checkMultiSig() error {
+	pubKeys, err := vm.popCounted(maxPubKeysPerMultiSig)
+	if err != nil {
+		return err
+	}
+	signatures, err := vm.popCounted(len(pubKeys))
+	if err != nil {
+		return err
+	}
+
+	for len(signatures) > 0 {
+		if len(pubKeys) < len(signatures) {
+			vm.pushBool(false)
+			return nil
+		}
+		if vm.checkSig(signatures[0], pubKeys[0]) {
+			signatures = signatures[1:]
+		}
+		pubKeys = pubKeys[1:]
+	}
+	vm.pushBool(true)
+
+	return nil
+}
+
+// checkLockTimeVerify fails unless the lock time of the transaction reached
+// the lock time on top of the stack, which is left in place. The input must
+// not be final, otherwise the lock time of the transaction would not apply.
+func (vm *engine) checkLockTimeVerify() error {
+	v, err := vm.peek()
+	if err != nil {
+		return err
+	}
+	lockTime, err := makeScriptNum(v, maxScriptNumLen)
+	if err != nil {
+		return err
+	}
+
+	if lockTime < 0 {
+		return errors.New("negative lock time")
+	}
+	if (lockTime < lockTimeThreshold) != (vm.tx.LockTime < lockTimeThreshold) {
+		return errors.New("lock time type mismatch")
+	}
+	if int64(lockTime) > int64(vm.tx.LockTime) {
+		return fmt.Errorf("lock time %d is not reached by transaction lock time %d", lockTime, vm.tx.LockTime)
+	}
+	if vm.tx.Vin[vm.inIdx].Sequence == sequenceFinal {
+		return errors.New("input is final")
+	}
+
+	return nil
+}
+
+// checkSequenceVerify fails unless the relative lock of the input is at least
+// the relative lock on top of the stack, which is left in place. A relative
+// lock with sequenceLockTimeDisabled set always passes.
+func (vm *engine) checkSequenceVerify() error {
+	v, err := vm.peek()
+	if err != nil {
+		return err
+	}
+	n, err := makeScriptNum(v, maxScriptNumLen)
+	if err != nil {
+		return err
+	}
+
+	if n < 0 {
+		return errors.New("negative sequence")
+	}
+	sequence := int64(n)
+	if sequence&sequenceLockTimeDisabled != 0 {
+		return nil
+	}
+
+	txSequence := int64(vm.tx.Vin[vm.inIdx].Sequence)
+	if txSequence&sequenceLockTimeDisabled != 0 {
+		return errors.New("relative lock of the input is disabled")
+	}
+	if sequence&sequenceLockTimeIsSeconds != txSequence&sequenceLockTimeIsSeconds {
+		return errors.New("relative lock type mismatch")
+	}
+	if sequence&sequenceLockTimeMask > txSequence&sequenceLockTimeMask {
+		return fmt.Errorf("relative lock %d is not reached by input sequence %#x", sequence&sequenceLockTimeMask, txSequence)
+	}
+
+	return nil
+}
+
+// popCounted pops a count, at most max, and then as many elements. They are
+// returned in the order they were pushed.
+func (vm *engine) popCounted(max int) ([][]byte, error) {
+	v, err := vm.pop()
+	if err != nil {
+		return nil, err
+	}
+	count, err := makeScriptNum(v, maxScriptNumLen)
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 || int(count) > max {
+		return nil, fmt.Errorf("count %d is out of range", count)
+	}
+	if int(count) > len(vm.stack) {
+		return nil, errEmptyStack
+	}
+
+	elements := make([][]byte, count)
+	copy(elements, vm.stack[len(vm.stack)-int(count):])
+	vm.stack = vm.stack[:len(vm.stack)-int(count)]
+
+	return elements, nil
+}
+
+func (vm *engine) push(v []byte) {
+	vm.stack = append(vm.stack, v)
+}
+
+func (vm *engine) pushBool(v bool) {
+	if v {
+		vm.push([]byte{1})
+	} else {
+		vm.push(nil)
+	}
+}
+
+func (vm *engine) peek() ([]byte, error) {
+	if len(vm.stack) == 0 {
+		return nil, errEmptyStack
+	}
+	return vm.stack[len(vm.stack)-1], nil
+}
+
+func (vm *engine) pop() ([]byte, error) {
+	v, err := vm.peek()
+	if err != nil {
+		return nil, err
+	}
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return v, nil
+}
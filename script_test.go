@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// spendingTx returns a transaction spending output 0 of a transaction locked
+// with scriptPubKey.
+func spendingTx(scriptPubKey []byte) (*Transaction, map[string]Transaction) {
+	prevTx := Transaction{
+		ID:   []byte{0x01},
+		Vout: []TXOutput{{Value: 10, ScriptPubKey: scriptPubKey}},
+	}
+	tx := &Transaction{
+		Vin:  []TXInput{{Txid: prevTx.ID, Vout: 0}},
+		Vout: []TXOutput{*NewTXOutput(10, "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM")},
+	}
+	return tx, map[string]Transaction{"01": prevTx}
+}
+
+func sign(t *testing.T, tx *Transaction, scriptPubKey []byte, privKey ecdsa.PrivateKey) []byte {
+	hash, err := tx.SignatureHash(0, scriptPubKey, 10, SigHashAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signHash(&privKey, hash, SigHashAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signature
+}
+
+func TestPayToPubKeyHash(t *testing.T) {
+	wallet := NewWallet()
+	scriptPubKey := PayToPubKeyHashScript(HashPubKey(wallet.PublicKey))
+	assert.Equal(t, HashPubKey(wallet.PublicKey), extractPubKeyHash(scriptPubKey))
+
+	tx, prevTXs := spendingTx(scriptPubKey)
+	tx.Sign(wallet.PrivateKey, prevTXs)
+	assert.True(t, tx.Verify(prevTXs), "Owner can spend.")
+	assert.True(t, tx.Vin[0].UsesKey(HashPubKey(wallet.PublicKey)))
+
+	tx.Vout[0].Value = 5
+	assert.False(t, tx.Verify(prevTXs), "Signature commits to outputs.")
+
+	thief := NewWallet()
+	tx, prevTXs = spendingTx(scriptPubKey)
+	tx.Sign(thief.PrivateKey, prevTXs)
+	assert.False(t, tx.Verify(prevTXs), "Other keys cannot spend.")
+}
+
+func TestCheckMultiSig(t *testing.T) {
+	wallets := []*Wallet{NewWallet(), NewWallet(), NewWallet()}
+	scriptPubKey := NewScriptBuilder().
+		AddInt64(2).
+		AddData(wallets[0].PublicKey).
+		AddData(wallets[1].PublicKey).
+		AddData(wallets[2].PublicKey).
+		AddInt64(3).
+		AddOp(OP_CHECKMULTISIG).
+		Script()
+	tx, _ := spendingTx(scriptPubKey)
+
+	sig0 := sign(t, tx, scriptPubKey, wallets[0].PrivateKey)
+	sig2 := sign(t, tx, scriptPubKey, wallets[2].PrivateKey)
+
+	scriptSig := NewScriptBuilder().AddData(sig0).AddData(sig2).Script()
+	assert.Nil(t, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "2 of 3 signatures unlock.")
+
+	scriptSig = NewScriptBuilder().AddData(sig2).AddData(sig0).Script()
+	assert.Equal(t, errEvalFalse, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "Signatures follow key order.")
+
+	scriptSig = NewScriptBuilder().AddData(sig0).AddData(sig0).Script()
+	assert.Equal(t, errEvalFalse, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "A key signs once.")
+}
+
+func TestPayToScriptHash(t *testing.T) {
+	wallets := []*Wallet{NewWallet(), NewWallet()}
+	redeemScript, err := MultiSigScript(1, [][]byte{wallets[0].PublicKey, wallets[1].PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nRequired, pubKeys, ok := extractMultiSig(redeemScript)
+	assert.True(t, ok)
+	assert.Equal(t, 1, nRequired)
+	assert.Equal(t, [][]byte{wallets[0].PublicKey, wallets[1].PublicKey}, pubKeys)
+
+	scriptPubKey := PayToScriptHashScript(HashPubKey(redeemScript))
+	assert.Equal(t, HashPubKey(redeemScript), extractScriptHash(scriptPubKey))
+	tx, _ := spendingTx(scriptPubKey)
+
+	sig := sign(t, tx, redeemScript, wallets[1].PrivateKey)
+	scriptSig := NewScriptBuilder().AddData(sig).AddData(redeemScript).Script()
+	assert.Nil(t, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "Redeem script unlocks.")
+
+	scriptSig = NewScriptBuilder().AddData(redeemScript).Script()
+	assert.Equal(t, errEmptyStack, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "Redeem script has to succeed.")
+
+	other, _ := MultiSigScript(1, [][]byte{wallets[1].PublicKey})
+	scriptSig = NewScriptBuilder().AddData(sign(t, tx, other, wallets[1].PrivateKey)).AddData(other).Script()
+	assert.Equal(t, errEvalFalse, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "Redeem script has to match the hash.")
+
+	_, err = MultiSigScript(3, [][]byte{wallets[0].PublicKey, wallets[1].PublicKey})
+	assert.NotNil(t, err, "Cannot require more signatures than keys.")
+}
+
+func TestPayToWitnessPubKeyHash(t *testing.T) {
+	wallet := NewWallet()
+	scriptPubKey := PayToWitnessPubKeyHashScript(HashPubKey(wallet.PublicKey))
+	assert.Equal(t, HashPubKey(wallet.PublicKey), extractWitnessPubKeyHash(scriptPubKey))
+	assert.Nil(t, extractPubKeyHash(scriptPubKey))
+
+	tx, prevTXs := spendingTx(scriptPubKey)
+	assert.NotNil(t, VerifyScript(nil, scriptPubKey, tx, 0, 10), "The program alone does not unlock.")
+	tx.Sign(wallet.PrivateKey, prevTXs)
+	assert.True(t, tx.Verify(prevTXs), "Owner can spend.")
+
+	thief := NewWallet()
+	tx, prevTXs = spendingTx(scriptPubKey)
+	tx.Sign(thief.PrivateKey, prevTXs)
+	assert.False(t, tx.Verify(prevTXs), "Other keys cannot spend.")
+
+	badLength := NewScriptBuilder().AddOp(OP_0).AddData(make([]byte, 25)).Script()
+	tx, _ = spendingTx(badLength)
+	assert.NotNil(t, VerifyScript(nil, badLength, tx, 0, 10), "Version 0 programs are 20 or 32 bytes.")
+}
+
+func TestPayToWitnessScriptHash(t *testing.T) {
+	wallet := NewWallet()
+	witnessScript, err := MultiSigScript(1, [][]byte{wallet.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scriptPubKey := PayToWitnessScriptHashScript(WitnessScriptHash(witnessScript))
+	assert.Equal(t, WitnessScriptHash(witnessScript), extractWitnessScriptHash(scriptPubKey))
+	tx, _ := spendingTx(scriptPubKey)
+
+	sig := sign(t, tx, witnessScript, wallet.PrivateKey)
+	scriptSig := NewScriptBuilder().AddData(sig).AddData(witnessScript).Script()
+	assert.Nil(t, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "Witness script unlocks.")
+
+	scriptSig = NewScriptBuilder().AddData(witnessScript).Script()
+	assert.Equal(t, errEmptyStack, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "Witness script has to succeed.")
+
+	other, _ := MultiSigScript(1, [][]byte{NewWallet().PublicKey})
+	scriptSig = NewScriptBuilder().AddData(sig).AddData(witnessScript).Script()
+	assert.NotNil(t, VerifyScript(scriptSig, PayToWitnessScriptHashScript(WitnessScriptHash(other)), tx, 0, 10), "Witness script has to match the program.")
+}
+
+// TestWitnessProgramSignatureScripts covers the consensus rule letting
+// signature scripts unlock version 0 witness programs, as transactions carry
+// no separate witnesses.
+func TestWitnessProgramSignatureScripts(t *testing.T) {
+	wallet := NewWallet()
+	pubKeyHash := HashPubKey(wallet.PublicKey)
+	scriptPubKey := PayToWitnessPubKeyHashScript(pubKeyHash)
+	tx, _ := spendingTx(scriptPubKey)
+
+	sig := sign(t, tx, PayToPubKeyHashScript(pubKeyHash), wallet.PrivateKey)
+	scriptSig := NewScriptBuilder().AddData(sig).AddData(wallet.PublicKey).Script()
+	assert.Nil(t, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "A key hash program runs as pay-to-pubkey-hash.")
+
+	programSig := sign(t, tx, scriptPubKey, wallet.PrivateKey)
+	scriptSig = NewScriptBuilder().AddData(programSig).AddData(wallet.PublicKey).Script()
+	assert.Equal(t, errEvalFalse, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "Signatures commit to the pay-to-pubkey-hash script code.")
+
+	scriptSig = NewScriptBuilder().AddData(sig).AddData(wallet.PublicKey).AddOp(OP_DUP).Script()
+	assert.NotNil(t, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "Signature scripts only push data.")
+
+	witnessScript, err := MultiSigScript(1, [][]byte{wallet.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig = sign(t, tx, witnessScript, wallet.PrivateKey)
+	scriptSig = NewScriptBuilder().AddData(sig).AddData(witnessScript).Script()
+	scriptPubKey = PayToWitnessScriptHashScript(WitnessScriptHash(witnessScript))
+	assert.Nil(t, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "A script hash program runs the last push on the others.")
+	assert.Equal(t, errEmptyStack, VerifyScript(nil, scriptPubKey, tx, 0, 10), "The witness script is pushed.")
+
+	scriptPubKey = NewScriptBuilder().AddOp(OP_0).AddData(HashPubKey(witnessScript)).Script()
+	assert.Equal(t, errEvalFalse, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "20 byte programs are key hashes, not script hashes.")
+
+	scriptPubKey = NewScriptBuilder().AddOp(OP_1).AddData(WitnessScriptHash(witnessScript)).Script()
+	assert.Nil(t, VerifyScript(nil, scriptPubKey, tx, 0, 10), "Later versions are left to future rules.")
+}
+
+func TestGetOutputType(t *testing.T) {
+	pubKey := NewWallet().PublicKey
+	multiSig, err := MultiSigScript(1, [][]byte{pubKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nullData, err := NullDataScript([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := map[OutputType][]byte{
+		PubKeyHashTy:          PayToPubKeyHashScript(HashPubKey(pubKey)),
+		ScriptHashTy:          PayToScriptHashScript(HashPubKey(multiSig)),
+		WitnessV0PubKeyHashTy: PayToWitnessPubKeyHashScript(HashPubKey(pubKey)),
+		WitnessV0ScriptHashTy: PayToWitnessScriptHashScript(WitnessScriptHash(multiSig)),
+		WitnessUnknownTy:      NewScriptBuilder().AddOp(OP_1).AddData(WitnessScriptHash(multiSig)).Script(),
+		MultiSigTy:            multiSig,
+		NullDataTy:            nullData,
+		NonStandardTy:         NewScriptBuilder().AddOp(OP_0).AddData(make([]byte, 25)).Script(),
+	}
+	for outputType, script := range types {
+		assert.Equal(t, outputType, GetOutputType(script), "Type of %s.", DisasmScript(script))
+	}
+	assert.Equal(t, "witness_v0_keyhash", WitnessV0PubKeyHashTy.String())
+}
+
+func TestCheckLockTimeVerify(t *testing.T) {
+	wallet := NewWallet()
+	scriptPubKey := NewScriptBuilder().
+		AddInt64(100).
+		AddOp(OP_CHECKLOCKTIMEVERIFY).
+		AddOp(OP_DROP).
+		AddOp(OP_DUP).
+		AddOp(OP_HASH160).
+		AddData(HashPubKey(wallet.PublicKey)).
+		AddOp(OP_EQUALVERIFY).
+		AddOp(OP_CHECKSIG).
+		Script()
+
+	for lockTime, spendable := range map[uint32]bool{99: false, 100: true, 1500000000: false} {
+		tx, prevTXs := spendingTx(scriptPubKey)
+		tx.LockTime = lockTime
+		tx.Sign(wallet.PrivateKey, prevTXs)
+		assert.Equal(t, spendable, tx.Verify(prevTXs), "Lock time %d.", lockTime)
+	}
+
+	tx, prevTXs := spendingTx(scriptPubKey)
+	tx.LockTime = 100
+	tx.Vin[0].Sequence = sequenceFinal
+	tx.Sign(wallet.PrivateKey, prevTXs)
+	assert.False(t, tx.Verify(prevTXs), "Final inputs disable the lock time.")
+}
+
+func TestCheckSequenceVerify(t *testing.T) {
+	scriptPubKey := NewScriptBuilder().
+		AddInt64(10).
+		AddOp(OP_CHECKSEQUENCEVERIFY).
+		Script()
+
+	for sequence, spendable := range map[uint32]bool{
+		9:                              false,
+		10:                             true,
+		sequenceLockTimeIsSeconds | 10: false,
+		sequenceLockTimeDisabled | 10:  false,
+		sequenceFinal:                  false,
+		100:                            true,
+	} {
+		tx, _ := spendingTx(scriptPubKey)
+		tx.Vin[0].Sequence = sequence
+		assert.Equal(t, spendable, VerifyScript(nil, scriptPubKey, tx, 0, 10) == nil, "Sequence %#x.", sequence)
+	}
+}
+
+func TestScriptRejects(t *testing.T) {
+	tx, _ := spendingTx(nil)
+	unspendable := NewScriptBuilder().AddOp(OP_RETURN).AddData([]byte("data")).Script()
+	assert.NotNil(t, VerifyScript(nil, unspendable, tx, 0, 10), "OP_RETURN outputs are unspendable.")
+
+	notPushOnly := NewScriptBuilder().AddInt64(1).AddOp(OP_DUP).Script()
+	assert.NotNil(t, VerifyScript(notPushOnly, []byte{OP_EQUAL}, tx, 0, 10), "Signature scripts only push data.")
+
+	assert.NotNil(t, VerifyScript(nil, []byte{OP_DATA_75, 0x01}, tx, 0, 10), "Truncated pushes are malformed.")
+	assert.NotNil(t, VerifyScript(nil, []byte{OP_DUP}, tx, 0, 10), "Empty stack is an error.")
+}
+
+func TestNullData(t *testing.T) {
+	script, err := NullDataScript(make([]byte, maxDataCarrierSize))
+	assert.Nil(t, err)
+	assert.True(t, isUnspendable(script))
+	data, ok := extractNullData(script)
+	assert.True(t, ok)
+	assert.Equal(t, make([]byte, maxDataCarrierSize), data)
+
+	_, err = NullDataScript(make([]byte, maxDataCarrierSize+1))
+	assert.NotNil(t, err, "Data is limited to maxDataCarrierSize bytes.")
+
+	_, ok = extractNullData(PayToPubKeyHashScript(make([]byte, 20)))
+	assert.False(t, ok)
+	assert.False(t, isUnspendable(PayToPubKeyHashScript(make([]byte, 20))))
+}
+
+func TestScriptNum(t *testing.T) {
+	for _, n := range []int64{0, 1, -1, 127, 128, -128, 255, 256, 32767, -32768, 500000000, 1 << 38} {
+		encoded := scriptNum(n).Bytes()
+		decoded, err := makeScriptNum(encoded, maxScriptNumLen)
+		assert.Nil(t, err)
+		assert.Equal(t, scriptNum(n), decoded)
+	}
+
+	_, err := makeScriptNum([]byte{0x01, 0x00}, maxScriptNumLen)
+	assert.NotNil(t, err, "Non-minimal numbers are rejected.")
+	assert.False(t, castToBool([]byte{0x00, 0x80}), "Negative zero is false.")
+}
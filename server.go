@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/gob"
 	"encoding/hex"
 	"fmt"
@@ -15,12 +16,13 @@ const (
 	protocol      = "tcp"
 	nodeVersion   = 1
 	commandLength = 12
+	magicLength   = 4
 )
 
 var (
 	nodeAddr        string
 	miningAddr      string
-	knownNodes      = []string{"localhost:3000"}
+	knownNodes      = []string{"localhost:" + MainNetParams.DefaultPort}
 	blocksInTransit = [][]byte{}
 	mempool         = make(map[string]Transaction)
 )
@@ -86,7 +88,13 @@ func StartServer(nodeID, minerAddr string) {
 	}
 }
 
+// sendData sends a message to addr, prefixed with the magic of the active
+// network.
 func sendData(addr string, data []byte) {
+	magic := make([]byte, magicLength)
+	binary.LittleEndian.PutUint32(magic, activeNet.Net)
+	data = append(magic, data...)
+
 	conn, err := net.Dial(protocol, addr)
 	if err != nil {
 		fmt.Printf("%s is not available\n", addr)
@@ -222,6 +230,17 @@ func handleConn(conn net.Conn, bc *Blockchain) {
 	if err != nil {
 		log.Panic(err)
 	}
+	if len(request) < magicLength+commandLength {
+		fmt.Println("Malformed message!")
+		conn.Close()
+		return
+	}
+	if binary.LittleEndian.Uint32(request[:magicLength]) != activeNet.Net {
+		fmt.Println("Received a message from another network, ignoring it")
+		conn.Close()
+		return
+	}
+	request = request[magicLength:]
 	command := bytesToCommand(request[:commandLength])
 	fmt.Printf("Received %s command\n", command)
 
@@ -302,9 +321,14 @@ func handleInv(request []byte, bc *Blockchain) {
 	fmt.Printf("Received inventory with %d %s(s)\n", len(payload.Items), payload.Type)
 
 	if payload.Type == "block" {
-		blocksInTransit = payload.Items
+		// Blocks are listed from the tip down. They are requested from the
+		// oldest so that each one is checked on top of its parent.
+		blocksInTransit = [][]byte{}
+		for i := len(payload.Items) - 1; i >= 0; i-- {
+			blocksInTransit = append(blocksInTransit, payload.Items[i])
+		}
 
-		blockHash := payload.Items[0]
+		blockHash := blocksInTransit[0]
 		sendGetData(payload.AddrFrom, "block", blockHash)
 
 		newInTransit := [][]byte{}
@@ -372,18 +396,26 @@ func handleBlock(request []byte, bc *Blockchain) {
 	block := DeserializeBlock(blockData)
 
 	fmt.Println("Received a new block!")
+	tip := bc.tip
 	bc.AddBlock(block)
 
 	fmt.Printf("Added block %x\n", block.Hash)
 
+	// The next blocks are checked against the UTXO set of the new tip.
+	if !bytes.Equal(bc.tip, tip) {
+		UTXOSet := UTXOSet{bc}
+		if bytes.Equal(block.PrevBlockHash, tip) {
+			UTXOSet.Update(block)
+		} else {
+			UTXOSet.Reindex()
+		}
+	}
+
 	if len(blocksInTransit) > 0 {
 		blockHash := blocksInTransit[0]
 		sendGetData(payload.AddrFrom, "block", blockHash)
 
 		blocksInTransit = blocksInTransit[1:]
-	} else {
-		UTXOSet := UTXOSet{bc}
-		UTXOSet.Reindex()
 	}
 }
 
@@ -402,7 +434,18 @@ func handleTx(request []byte, bc *Blockchain) {
 
 	txData := payload.Transaction
 	tx := DeserializeTransaction(txData)
+
+	// Transactions that cannot be mined in the next block are not relayed.
+	err = UTXOSet{bc}.CheckPending(&tx)
+	if err == nil {
+		err = bc.CheckLocks(&tx, bc.GetBestHeight()+1, clock().Unix())
+	}
+	if err != nil {
+		fmt.Printf("Rejected transaction %x: %v\n", tx.ID, err)
+		return
+	}
 	mempool[hex.EncodeToString(tx.ID)] = tx
+	bc.AddPending(&tx)
 
 	if nodeAddr == knownNodes[0] {
 		for _, node := range knownNodes {
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SigHashType selects the parts of a transaction a signature commits to. It
+// is appended to the signature.
+type SigHashType byte
+
+// Signature hash types. SigHashAnyOneCanPay may be combined with the others.
+const (
+	// SigHashAll commits to every input and output.
+	SigHashAll SigHashType = 0x01
+	// SigHashNone commits to the inputs but to no output, so anyone may
+	// choose where the coins go.
+	SigHashNone SigHashType = 0x02
+	// SigHashSingle commits to the inputs and to the output with the index of
+	// the signed input.
+	SigHashSingle SigHashType = 0x03
+	// SigHashAnyOneCanPay commits to the signed input only, so others may add
+	// inputs.
+	SigHashAnyOneCanPay SigHashType = 0x80
+
+	sigHashMask = 0x1f
+)
+
+var sigHashNames = map[SigHashType]string{
+	SigHashAll:    "ALL",
+	SigHashNone:   "NONE",
+	SigHashSingle: "SINGLE",
+}
+
+// isValid checks whether t is a known hash type.
+func (t SigHashType) isValid() bool {
+	return t&^(sigHashMask|SigHashAnyOneCanPay) == 0 && sigHashNames[t&sigHashMask] != ""
+}
+
+func (t SigHashType) String() string {
+	if !t.isValid() {
+		return fmt.Sprintf("UNKNOWN(%#x)", byte(t))
+	}
+	name := sigHashNames[t&sigHashMask]
+	if t&SigHashAnyOneCanPay != 0 {
+		name += "|ANYONECANPAY"
+	}
+	return name
+}
+
+// ParseSigHashType parses a hash type written as by String, such as
+// ALL|ANYONECANPAY.
+func ParseSigHashType(s string) (SigHashType, error) {
+	parts := strings.Split(strings.ToUpper(s), "|")
+
+	var t SigHashType
+	for name, base := range map[string]SigHashType{"ALL": SigHashAll, "NONE": SigHashNone, "SINGLE": SigHashSingle} {
+		if parts[0] == name {
+			t = base
+		}
+	}
+	if t == 0 || len(parts) > 2 {
+		return 0, fmt.Errorf("unknown signature hash type %q", s)
+	}
+	if len(parts) == 2 {
+		if parts[1] != "ANYONECANPAY" {
+			return 0, fmt.Errorf("unknown signature hash type %q", s)
+		}
+		t |= SigHashAnyOneCanPay
+	}
+
+	return t, nil
+}
+
+// TxSigHashes caches the hashes of the parts of a transaction shared by the
+// signature hashes of all its inputs, so that checking every input does not
+// rehash the whole transaction each time.
+type TxSigHashes struct {
+	HashPrevOuts []byte
+	HashSequence []byte
+	HashOutputs  []byte
+}
+
+// NewTxSigHashes computes the shared hashes of tx.
+func NewTxSigHashes(tx *Transaction) *TxSigHashes {
+	var prevOuts, sequences, outputs bytes.Buffer
+
+	for _, vin := range tx.Vin {
+		writeBytes(&prevOuts, vin.Txid)
+		writeUint32(&prevOuts, uint32(int32(vin.Vout)))
+		writeUint32(&sequences, vin.Sequence)
+	}
+	for _, vout := range tx.Vout {
+		writeOutput(&outputs, vout)
+	}
+
+	hashPrevOuts := sha256.Sum256(prevOuts.Bytes())
+	hashSequence := sha256.Sum256(sequences.Bytes())
+	hashOutputs := sha256.Sum256(outputs.Bytes())
+
+	return &TxSigHashes{
+		HashPrevOuts: hashPrevOuts[:],
+		HashSequence: hashSequence[:],
+		HashOutputs:  hashOutputs[:],
+	}
+}
+
+// SignatureHash returns the hash signed to spend input inIdx with hashType.
+// subScript is the script being run, usually the locking script of the output
+// being spent, and amount the value of that output.
+func (tx *Transaction) SignatureHash(inIdx int, subScript []byte, amount int, hashType SigHashType) ([]byte, error) {
+	return tx.signatureHash(NewTxSigHashes(tx), inIdx, subScript, amount, hashType)
+}
+
+// signatureHash computes the signature hash from the shared hashes of tx. It
+// is the SHA-256 of:
+//
+//	HashPrevOuts, or zeros with SigHashAnyOneCanPay
+//	HashSequence, or zeros unless the type is SigHashAll
+//	the outpoint and the subScript of the input, as Bytes, the amount of the
+//	output it spends, as uint64, and its sequence
+//	HashOutputs with SigHashAll, the hash of the output of the same index
+//	with SigHashSingle, or zeros with SigHashNone
+//	the lock time and the hash type, as uint32
+//
+// using the encodings of transaction_encoding.go. Committing to the amount
+// lets offline signers trust the value they are told they spend: a signature
+// made for another amount does not verify.
+func (tx *Transaction) signatureHash(hashes *TxSigHashes, inIdx int, subScript []byte, amount int, hashType SigHashType) ([]byte, error) {
+	if !hashType.isValid() {
+		return nil, fmt.Errorf("unknown signature hash type %#x", byte(hashType))
+	}
+	if inIdx < 0 || inIdx >= len(tx.Vin) {
+		return nil, fmt.Errorf("input %d is out of range", inIdx)
+	}
+	base := hashType & sigHashMask
+	anyOneCanPay := hashType&SigHashAnyOneCanPay != 0
+
+	var zeroHash [sha256.Size]byte
+	var msg bytes.Buffer
+
+	if anyOneCanPay {
+		msg.Write(zeroHash[:])
+	} else {
+		msg.Write(hashes.HashPrevOuts)
+	}
+	if anyOneCanPay || base != SigHashAll {
+		msg.Write(zeroHash[:])
+	} else {
+		msg.Write(hashes.HashSequence)
+	}
+
+	vin := tx.Vin[inIdx]
+	writeBytes(&msg, vin.Txid)
+	writeUint32(&msg, uint32(int32(vin.Vout)))
+	writeBytes(&msg, subScript)
+	writeUint64(&msg, uint64(int64(amount)))
+	writeUint32(&msg, vin.Sequence)
+
+	switch base {
+	case SigHashAll:
+		msg.Write(hashes.HashOutputs)
+	case SigHashSingle:
+		if inIdx >= len(tx.Vout) {
+			return nil, errors.New("no output to sign with SIGHASH_SINGLE")
+		}
+		var output bytes.Buffer
+		writeOutput(&output, tx.Vout[inIdx])
+		hashOutput := sha256.Sum256(output.Bytes())
+		msg.Write(hashOutput[:])
+	default:
+		msg.Write(zeroHash[:])
+	}
+
+	writeUint32(&msg, tx.LockTime)
+	writeUint32(&msg, uint32(hashType))
+
+	hash := sha256.Sum256(msg.Bytes())
+	return hash[:], nil
+}
+
+// signHash signs hash with privKey and appends hashType to the signature.
+// Keys that are not on the curve of the active network cannot sign.
+func signHash(privKey *ecdsa.PrivateKey, hash []byte, hashType SigHashType) ([]byte, error) {
+	curve, err := signingCurve(privKey)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := curve.Sign(privKey, hash)
+	if err != nil {
+		return nil, err
+	}
+	return append(signature, byte(hashType)), nil
+}
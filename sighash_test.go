@@ -0,0 +1,157 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// signInput signs input inIdx of tx, spending a pay-to-pubkey-hash output of
+// wallet, with hashType.
+func signInput(t *testing.T, tx *Transaction, inIdx int, wallet *Wallet, hashType SigHashType) {
+	scriptPubKey := PayToPubKeyHashScript(HashPubKey(wallet.PublicKey))
+	hash, err := tx.SignatureHash(inIdx, scriptPubKey, 10, hashType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signHash(&wallet.PrivateKey, hash, hashType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Vin[inIdx].ScriptSig = NewScriptBuilder().AddData(signature).AddData(wallet.PublicKey).Script()
+}
+
+func verifyInput(tx *Transaction, inIdx int, wallet *Wallet) error {
+	scriptPubKey := PayToPubKeyHashScript(HashPubKey(wallet.PublicKey))
+	return VerifyScript(tx.Vin[inIdx].ScriptSig, scriptPubKey, tx, inIdx, 10)
+}
+
+func TestSigHashTypes(t *testing.T) {
+	wallet := NewWallet()
+	other := *NewTXOutput(3, "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM")
+	newTx := func() *Transaction {
+		return &Transaction{
+			Vin: []TXInput{
+				{Txid: []byte{0x01}, Vout: 0, Sequence: sequenceFinal},
+				{Txid: []byte{0x02}, Vout: 0, Sequence: sequenceFinal},
+			},
+			Vout: []TXOutput{
+				*NewTXOutput(10, string(wallet.GetAddress())),
+				*NewTXOutput(5, string(wallet.GetAddress())),
+			},
+		}
+	}
+
+	tests := []struct {
+		hashType SigHashType
+		change   func(tx *Transaction)
+		valid    bool
+	}{
+		{SigHashAll, func(tx *Transaction) {}, true},
+		{SigHashAll, func(tx *Transaction) { tx.Vout[1] = other }, false},
+		{SigHashAll, func(tx *Transaction) { tx.Vin[1].Sequence = 0 }, false},
+		{SigHashNone, func(tx *Transaction) { tx.Vout = []TXOutput{other} }, true},
+		{SigHashNone, func(tx *Transaction) { tx.Vin[1].Sequence = 0 }, true},
+		{SigHashNone, func(tx *Transaction) { tx.Vin[1].Vout = 1 }, false},
+		{SigHashSingle, func(tx *Transaction) { tx.Vout[1] = other }, true},
+		{SigHashSingle, func(tx *Transaction) { tx.Vout = append(tx.Vout, other) }, true},
+		{SigHashSingle, func(tx *Transaction) { tx.Vout[0] = other }, false},
+		{SigHashAll | SigHashAnyOneCanPay, func(tx *Transaction) { tx.Vin = tx.Vin[:1] }, true},
+		{SigHashAll | SigHashAnyOneCanPay, func(tx *Transaction) { tx.Vin[1].Txid = []byte{0x03} }, true},
+		{SigHashAll | SigHashAnyOneCanPay, func(tx *Transaction) { tx.Vout[1] = other }, false},
+		{SigHashNone | SigHashAnyOneCanPay, func(tx *Transaction) { tx.Vin, tx.Vout = tx.Vin[:1], nil }, true},
+		{SigHashAll, func(tx *Transaction) { tx.LockTime = 1 }, false},
+	}
+
+	for i, test := range tests {
+		tx := newTx()
+		signInput(t, tx, 0, wallet, test.hashType)
+		assert.Nil(t, verifyInput(tx, 0, wallet), "Test %d before changes.", i)
+
+		test.change(tx)
+		err := verifyInput(tx, 0, wallet)
+		if test.valid {
+			assert.Nil(t, err, "Test %d: %s.", i, test.hashType)
+		} else {
+			assert.NotNil(t, err, "Test %d: %s.", i, test.hashType)
+		}
+	}
+}
+
+func TestSigHashCrowdfunding(t *testing.T) {
+	funders := []*Wallet{NewWallet(), NewWallet()}
+	goal := *NewTXOutput(20, "16UwLL9Risc3QfPqBUvKofHmBQ7wMtjvM")
+
+	// Each funder signs their own input to the same goal, without knowing
+	// the others.
+	tx := &Transaction{Vout: []TXOutput{goal}}
+	for i, funder := range funders {
+		pledge := &Transaction{
+			Vin:  []TXInput{{Txid: []byte{byte(i + 1)}, Vout: 0, Sequence: sequenceFinal}},
+			Vout: []TXOutput{goal},
+		}
+		signInput(t, pledge, 0, funder, SigHashAll|SigHashAnyOneCanPay)
+		tx.Vin = append(tx.Vin, pledge.Vin[0])
+	}
+
+	for i, funder := range funders {
+		assert.Nil(t, verifyInput(tx, i, funder), "Pledge %d.", i)
+	}
+}
+
+func TestSigHashRejects(t *testing.T) {
+	wallet := NewWallet()
+	tx := &Transaction{
+		Vin:  []TXInput{{Txid: []byte{0x01}, Vout: 0}, {Txid: []byte{0x02}, Vout: 0}},
+		Vout: []TXOutput{*NewTXOutput(10, string(wallet.GetAddress()))},
+	}
+	scriptPubKey := PayToPubKeyHashScript(HashPubKey(wallet.PublicKey))
+
+	_, err := tx.SignatureHash(1, scriptPubKey, 10, SigHashSingle)
+	assert.NotNil(t, err, "No output matches input 1.")
+	_, err = tx.SignatureHash(0, scriptPubKey, 10, 0x04)
+	assert.NotNil(t, err)
+
+	hash, err := tx.SignatureHash(0, scriptPubKey, 10, SigHashAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signHash(&wallet.PrivateKey, hash, SigHashAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scriptSig := NewScriptBuilder().AddData(signature).AddData(wallet.PublicKey).Script()
+	assert.Nil(t, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10))
+	assert.NotNil(t, VerifyScript(scriptSig, scriptPubKey, tx, 0, 11), "Signatures commit to the amount spent.")
+
+	for _, hashType := range []byte{0x00, 0x04, 0x41, byte(SigHashAnyOneCanPay), byte(SigHashNone)} {
+		signature[signatureLen] = hashType
+		scriptSig := NewScriptBuilder().AddData(signature).AddData(wallet.PublicKey).Script()
+		assert.NotNil(t, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10), "Hash type %#x.", hashType)
+	}
+
+	// Signatures without a hash type are rejected.
+	scriptSig = NewScriptBuilder().AddData(signature[:signatureLen]).AddData(wallet.PublicKey).Script()
+	assert.NotNil(t, VerifyScript(scriptSig, scriptPubKey, tx, 0, 10))
+}
+
+func TestParseSigHashType(t *testing.T) {
+	for _, hashType := range []SigHashType{
+		SigHashAll, SigHashNone, SigHashSingle,
+		SigHashAll | SigHashAnyOneCanPay, SigHashNone | SigHashAnyOneCanPay, SigHashSingle | SigHashAnyOneCanPay,
+	} {
+		parsed, err := ParseSigHashType(hashType.String())
+		assert.Nil(t, err)
+		assert.Equal(t, hashType, parsed)
+	}
+
+	parsed, err := ParseSigHashType("single|anyonecanpay")
+	assert.Nil(t, err)
+	assert.Equal(t, SigHashSingle|SigHashAnyOneCanPay, parsed)
+
+	for _, s := range []string{"", "ANYONECANPAY", "ALL|NONE", "ALL|ANYONECANPAY|ANYONECANPAY"} {
+		_, err := ParseSigHashType(s)
+		assert.NotNil(t, err, "Type %q.", s)
+	}
+	assert.Equal(t, "UNKNOWN(0x4)", SigHashType(0x04).String())
+}
@@ -3,25 +3,22 @@ package main
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
-	"encoding/gob"
 	"encoding/hex"
 	"fmt"
 	"log"
-	"math/big"
 	"strings"
 )
 
-// subsidy is the amount of reward.
-const subsidy = 10
-
 // Transaction represents a Bitcoin transaction.
+// LockTime is a block height, or a Unix timestamp from lockTimeThreshold up,
+// before which the transaction cannot be mined unless all its inputs are
+// final. OP_CHECKLOCKTIMEVERIFY compares against it.
 type Transaction struct {
-	ID   []byte
-	Vin  []TXInput
-	Vout []TXOutput
+	ID       []byte
+	Vin      []TXInput
+	Vout     []TXOutput
+	LockTime uint32
 }
 
 // IsCoinbase checks whether the transaction is coinbase.
@@ -29,32 +26,69 @@ func (tx *Transaction) IsCoinbase() bool {
 	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
 }
 
-// Sign signs each input of a Transaction.
+// Sign signs each input of a Transaction spending pay-to-pubkey-hash or
+// version 0 witness public key hash outputs of privKey. The signatures commit to the whole transaction.
 func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
 	if tx.IsCoinbase() {
 		return
 	}
 
-	txCopy := tx.TrimmedCopy()
+	hashes := NewTxSigHashes(tx)
+	for inID := range tx.Vin {
+		err := tx.signInput(inID, &privKey, prevTXs, hashes)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+}
+
+// SignWithWallets signs each input of a Transaction spending a
+// pay-to-pubkey-hash or version 0 witness public key hash output with the
+// key of wallets it is locked to.
+func (tx *Transaction) SignWithWallets(wallets *Wallets, prevTXs map[string]Transaction) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+	if wallets.IsLocked() {
+		return errWalletLocked
+	}
 
-	for inID, vin := range txCopy.Vin {
+	hashes := NewTxSigHashes(tx)
+	for inID, vin := range tx.Vin {
 		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		// Set to nil, double-check.
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		// Reset PubKey to nil.
-		txCopy.Vin[inID].PubKey = nil
-
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.ID)
+		pubKeyHash := extractPubKeyHash(scriptCode(prevTx.Vout[vin.Vout].ScriptPubKey))
+		wallet := wallets.FindWallet(pubKeyHash)
+		if pubKeyHash == nil || wallet == nil {
+			return fmt.Errorf("no key of the wallet file can sign input %d", inID)
+		}
+
+		err := tx.signInput(inID, &wallet.PrivateKey, prevTXs, hashes)
 		if err != nil {
-			log.Panic(err)
+			return err
 		}
+	}
+
+	return nil
+}
 
-		signature := append(r.Bytes(), s.Bytes()...)
+// signInput signs input inID, spending a pay-to-pubkey-hash or version 0
+// witness public key hash output of privKey, with SigHashAll.
+func (tx *Transaction) signInput(inID int, privKey *ecdsa.PrivateKey, prevTXs map[string]Transaction, hashes *TxSigHashes) error {
+	vin := tx.Vin[inID]
+	prevOut := prevTXs[hex.EncodeToString(vin.Txid)].Vout[vin.Vout]
+	hash, err := tx.signatureHash(hashes, inID, scriptCode(prevOut.ScriptPubKey), prevOut.Value, SigHashAll)
+	if err != nil {
+		return err
+	}
 
-		tx.Vin[inID].Signature = signature
+	signature, err := signHash(privKey, hash, SigHashAll)
+	if err != nil {
+		return err
 	}
+
+	pubKey := encodePubKey(&privKey.PublicKey)
+	tx.Vin[inID].ScriptSig = NewScriptBuilder().AddData(signature).AddData(pubKey).Script()
+	return nil
 }
 
 // TrimmedCopy creates a trimmed copy of Transaction to be used in signing.
@@ -65,51 +99,58 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	)
 
 	for _, vin := range tx.Vin {
-		// Note: TXInput.Signature and TXInput.PubKey should be set to nil.
+		// Note: TXInput.ScriptSig should be set to nil.
 		inputs = append(inputs, TXInput{
 			Txid:      vin.Txid,
 			Vout:      vin.Vout,
-			Signature: nil,
-			PubKey:    nil,
+			ScriptSig: nil,
+			Sequence:  vin.Sequence,
 		})
 	}
 
 	for _, vout := range tx.Vout {
 		outputs = append(outputs, TXOutput{
-			Value:      vout.Value,
-			PubKeyHash: vout.PubKeyHash,
+			Value:        vout.Value,
+			ScriptPubKey: vout.ScriptPubKey,
 		})
 	}
 
 	txCopy := Transaction{
-		ID:   tx.ID,
-		Vin:  inputs,
-		Vout: outputs,
+		ID:       tx.ID,
+		Vin:      inputs,
+		Vout:     outputs,
+		LockTime: tx.LockTime,
 	}
 	return txCopy
 }
 
-// Hash returns the hash of the Transaction.
-func (tx *Transaction) Hash() []byte {
-	var hash [32]byte
+// WitnessID returns the wtxid of the Transaction: the SHA-256 of its
+// canonical encoding, witnesses included. Signing changes it.
+func (tx *Transaction) WitnessID() []byte {
+	hash := sha256.Sum256(tx.Serialize())
 
-	txCopy := *tx
-	txCopy.ID = []byte{}
+	return hash[:]
+}
 
-	hash = sha256.Sum256(txCopy.Serialize())
+// ComputeID returns the txid of the Transaction. It covers non-witness data
+// only: the signature scripts of inputs are their witnesses and are left
+// out, so neither signing nor re-encoding a signature changes it and
+// transactions spending unconfirmed outputs stay valid.
+func (tx *Transaction) ComputeID() []byte {
+	if tx.IsCoinbase() {
+		// The coinbase script holds data, not a witness.
+		return tx.WitnessID()
+	}
 
-	return hash[:]
+	txCopy := tx.TrimmedCopy()
+	return txCopy.WitnessID()
 }
 
-// Serialize returns a serialized Transaction.
+// Serialize returns the canonical encoding of the Transaction, described in
+// transaction_encoding.go.
 func (tx Transaction) Serialize() []byte {
 	var encoded bytes.Buffer
-
-	enc := gob.NewEncoder(&encoded)
-	err := enc.Encode(tx)
-	if err != nil {
-		log.Panic(err)
-	}
+	tx.encode(&encoded)
 
 	return encoded.Bytes()
 }
@@ -118,8 +159,7 @@ func (tx Transaction) Serialize() []byte {
 func DeserializeTransaction(data []byte) Transaction {
 	var transaction Transaction
 
-	decoder := gob.NewDecoder(bytes.NewReader(data))
-	err := decoder.Decode(&transaction)
+	err := transaction.Deserialize(data)
 	if err != nil {
 		log.Panic(err)
 	}
@@ -139,31 +179,24 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
+	// Unspendable outputs may only carry a bounded amount of data.
+	for _, vout := range tx.Vout {
+		if _, ok := extractNullData(vout.ScriptPubKey); isUnspendable(vout.ScriptPubKey) && !ok {
+			return false
+		}
+	}
 
+	hashes := NewTxSigHashes(tx)
 	for inID, vin := range tx.Vin {
-		// Check signature in input.
+		// Run the input script against the locking script it spends.
 		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Vin[inID].PubKey = nil
-
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(vin.Signature)
-		r.SetBytes(vin.Signature[:(sigLen / 2)])
-		s.SetBytes(vin.Signature[(sigLen / 2):])
-
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(vin.PubKey)
-		x.SetBytes(vin.PubKey[:(keyLen / 2)])
-		y.SetBytes(vin.PubKey[(keyLen / 2):])
-
-		rawPubKey := ecdsa.PublicKey{curve, &x, &y}
-		if ecdsa.Verify(&rawPubKey, txCopy.ID, &r, &s) == false {
+		if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return false
+		}
+
+		prevOut := prevTx.Vout[vin.Vout]
+		err := verifyScript(vin.ScriptSig, prevOut.ScriptPubKey, tx, inID, prevOut.Value, hashes)
+		if err != nil {
 			return false
 		}
 	}
@@ -181,14 +214,20 @@ func (tx *Transaction) String() string {
 		lines = append(lines, fmt.Sprintf("   Input %d:", i))
 		lines = append(lines, fmt.Sprintf("    TXID:      %x", input.Txid))
 		lines = append(lines, fmt.Sprintf("    Out:       %d", input.Vout))
-		lines = append(lines, fmt.Sprintf("    Signature: %x", input.Signature))
-		lines = append(lines, fmt.Sprintf("    PubKey:    %x", input.PubKey))
+		lines = append(lines, fmt.Sprintf("    ScriptSig: %s", DisasmScript(input.ScriptSig)))
+		if input.Sequence != sequenceFinal {
+			lines = append(lines, fmt.Sprintf("    Sequence:  %#x", input.Sequence))
+		}
 	}
 
 	for i, output := range tx.Vout {
 		lines = append(lines, fmt.Sprintf("   Output: %d:", i))
 		lines = append(lines, fmt.Sprintf("    Value: %d", output.Value))
-		lines = append(lines, fmt.Sprintf("    Script: %x", output.PubKeyHash))
+		lines = append(lines, fmt.Sprintf("    Script: %s", DisasmScript(output.ScriptPubKey)))
+	}
+
+	if tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("   LockTime: %d", tx.LockTime))
 	}
 
 	return strings.Join(lines, "\n")
@@ -203,58 +242,158 @@ func NewCoinbaseTX(to, data string) *Transaction {
 	txin := TXInput{
 		Txid:      []byte{},
 		Vout:      -1,
-		Signature: nil,
-		PubKey:    []byte(data),
+		ScriptSig: []byte(data),
+		Sequence:  sequenceFinal,
 	}
-	txout := NewTXOutput(subsidy, to)
+	txout := NewTXOutput(activeNet.Subsidy, to)
 	tx := Transaction{
 		ID:   nil,
 		Vin:  []TXInput{txin},
 		Vout: []TXOutput{*txout},
 	}
 
-	tx.ID = tx.Hash()
+	tx.ID = tx.ComputeID()
 	return &tx
 }
 
 // NewUTXOTransaction creates a new transaction.
-func NewUTXOTransaction(wallet *Wallet, to string, amount int, UTXOSet *UTXOSet) *Transaction {
+// A non-zero lockTime keeps it from being mined before that block height or
+// Unix timestamp. control selects the coins spent and the fee, defaults
+// apply if it is nil.
+func NewUTXOTransaction(wallet *Wallet, to string, amount int, lockTime uint32, control *CoinControl, UTXOSet *UTXOSet) *Transaction {
+	return NewSendManyTransaction(wallet, []Payment{{to, amount}}, lockTime, control, UTXOSet)
+}
+
+// NewSendManyTransaction creates a transaction with an output for each
+// payment, in order, funded by the coins of wallet at its Base58 and Bech32
+// addresses. Change goes to its Base58 address. It takes the same options as
+// NewUTXOTransaction.
+func NewSendManyTransaction(wallet *Wallet, payments []Payment, lockTime uint32, control *CoinControl, UTXOSet *UTXOSet) *Transaction {
+	from := fmt.Sprintf("%s", wallet.GetAddress())
+	coins := UTXOSet.FindCoins(wallet.LockScripts()...)
+	changeAddr := func() string { return from }
+
+	tx := newPaymentTransaction(coins, payments, lockTime, control, changeAddr)
+	UTXOSet.Blockchain.SignTransaction(tx, wallet.PrivateKey)
+	return tx
+}
+
+// NewWalletTransaction creates a transaction paying payments from the coins
+// of any address of wallets, signing each input with its key. Change goes to
+// a new address of wallets, which the caller has to save.
+func NewWalletTransaction(wallets *Wallets, payments []Payment, lockTime uint32, control *CoinControl, UTXOSet *UTXOSet) *Transaction {
+	if wallets.IsLocked() {
+		log.Panic(errWalletLocked)
+	}
+
+	var lockScripts [][]byte
+	for _, wallet := range wallets.Wallets {
+		lockScripts = append(lockScripts, wallet.LockScripts()...)
+	}
+	coins := UTXOSet.FindCoins(lockScripts...)
+
+	tx := newPaymentTransaction(coins, payments, lockTime, control, wallets.CreateChangeWallet)
+	UTXOSet.Blockchain.SignWalletTransaction(tx, wallets)
+	return tx
+}
+
+// newPaymentTransaction creates an unsigned transaction paying payments from
+// coins picked by control. changeAddr is only called if there is change.
+func newPaymentTransaction(coins []Coin, payments []Payment, lockTime uint32, control *CoinControl, changeAddr func() string) *Transaction {
 	var (
 		inputs  []TXInput
 		outputs []TXOutput
 	)
 
-	pubKeyHash := HashPubKey(wallet.PublicKey)
-	acc, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, amount)
-	if acc < amount {
-		log.Panic("error: not enough funds")
+	if err := validatePayments(payments); err != nil {
+		log.Panic(err)
+	}
+	if control == nil {
+		control = &CoinControl{}
+	}
+
+	// Build a list of outputs.
+	// outputs that’s locked with the receiver address. This is the actual transferring of coins to other address.
+	amount := 0
+	for _, payment := range payments {
+		outputs = append(outputs, *NewTXOutput(payment.Amount, payment.Address))
+		amount += payment.Amount
+	}
+
+	selection, err := control.SelectCoins(coins, amount, outputsBaseSize(outputs))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Inputs have to be non-final for the lock time to apply.
+	sequence := uint32(sequenceFinal)
+	if lockTime != 0 {
+		sequence = sequenceLockTimeEnabled
 	}
 
 	// Build a list of inputs.
-	for txid, outs := range validOutputs {
-		txID, err := hex.DecodeString(txid)
-		if err != nil {
-			log.Panic(err)
+	for _, coin := range selection.Coins {
+		input := TXInput{
+			Txid:      coin.Txid,
+			Vout:      coin.Vout,
+			ScriptSig: nil,
+			Sequence:  sequence,
 		}
+		inputs = append(inputs, input)
+	}
+
+	if selection.Change > 0 {
+		// outputs that’s locked with the sender address. This is a change.
+		outputs = append(outputs, *NewTXOutput(selection.Change, changeAddr()))
+	}
 
-		for _, out := range outs {
-			input := TXInput{
-				Txid:      txID,
-				Vout:      out,
-				Signature: nil,
-				PubKey:    wallet.PublicKey,
-			}
-			inputs = append(inputs, input)
+	tx := Transaction{
+		ID:       nil,
+		Vin:      inputs,
+		Vout:     outputs,
+		LockTime: lockTime,
+	}
+	tx.ID = tx.ComputeID()
+	return &tx
+}
+
+// outputsBaseSize returns the encoded size of a transaction with outputs
+// and no inputs, the base of fee estimates.
+func outputsBaseSize(outputs []TXOutput) int {
+	var buf bytes.Buffer
+	for _, out := range outputs {
+		writeOutput(&buf, out)
+	}
+	return txOverheadSize + buf.Len()
+}
+
+// NewDataTransaction creates a transaction anchoring data in an unspendable
+// output. A transaction needs an input, so it spends an output of wallet, at
+// its Base58 or Bech32 address, and sends its value back to the Base58
+// address.
+func NewDataTransaction(wallet *Wallet, data []byte, UTXOSet *UTXOSet) *Transaction {
+	dataScript, err := NullDataScript(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	acc := 0
+	var inputs []TXInput
+	for _, coin := range UTXOSet.FindCoins(wallet.LockScripts()...) {
+		if acc >= 1 {
+			break
 		}
+		acc += coin.Output.Value
+		inputs = append(inputs, TXInput{Txid: coin.Txid, Vout: coin.Vout, Sequence: sequenceFinal})
+	}
+	if acc < 1 {
+		log.Panic("error: not enough funds")
 	}
 
-	// Build a list of outputs.
-	// outputs that’s locked with the receiver address. This is the actual transferring of coins to other address.
 	from := fmt.Sprintf("%s", wallet.GetAddress())
-	outputs = append(outputs, *NewTXOutput(amount, to))
-	if acc > amount {
-		// outputs that’s locked with the sender address. This is a change.
-		outputs = append(outputs, *NewTXOutput(acc-amount, from))
+	outputs := []TXOutput{
+		{Value: 0, ScriptPubKey: dataScript},
+		*NewTXOutput(acc, from),
 	}
 
 	tx := Transaction{
@@ -262,7 +401,7 @@ func NewUTXOTransaction(wallet *Wallet, to string, amount int, UTXOSet *UTXOSet)
 		Vin:  inputs,
 		Vout: outputs,
 	}
-	tx.ID = tx.Hash()
+	tx.ID = tx.ComputeID()
 	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
 	return &tx
 }
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Canonical transaction encoding.
+//
+// Transactions are serialized field by field in a fixed order, so that any
+// implementation produces the same bytes and therefore the same IDs and
+// signature hashes. Integers are little endian. VarInt is Bitcoin's
+// CompactSize: values below 0xfd take a single byte, larger values a 0xfd,
+// 0xfe or 0xff marker followed by 2, 4 or 8 bytes. Only the shortest form is
+// accepted. Bytes are a VarInt length followed by the bytes.
+//
+//	Transaction: VarInt input count, inputs, VarInt output count, outputs,
+//	             uint32 LockTime
+//	Input:       Bytes Txid, uint32 Vout, Bytes ScriptSig, uint32 Sequence
+//	Output:      int64 Value, Bytes ScriptPubKey
+//
+// The Vout of coinbase inputs, -1, is encoded as 0xffffffff.
+//
+// The signature scripts of inputs are their witnesses. The ID of a
+// transaction, its txid, is not encoded. It is the SHA-256 of the encoding of
+// the transaction with the witnesses left empty, except for coinbase inputs
+// whose script holds the coinbase data. The wtxid is the SHA-256 of the whole
+// encoding.
+
+var errNonCanonicalVarInt = errors.New("varint is not canonically encoded")
+
+// writeVarInt appends the CompactSize encoding of n to buf.
+func writeVarInt(buf *bytes.Buffer, n uint64) {
+	var b [9]byte
+
+	switch {
+	case n < 0xfd:
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		b[0] = 0xfd
+		binary.LittleEndian.PutUint16(b[1:], uint16(n))
+		buf.Write(b[:3])
+	case n <= math.MaxUint32:
+		b[0] = 0xfe
+		binary.LittleEndian.PutUint32(b[1:], uint32(n))
+		buf.Write(b[:5])
+	default:
+		b[0] = 0xff
+		binary.LittleEndian.PutUint64(b[1:], n)
+		buf.Write(b[:9])
+	}
+}
+
+// readVarInt reads a CompactSize integer, rejecting non-minimal encodings.
+func readVarInt(r *bytes.Reader) (uint64, error) {
+	marker, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		n   uint64
+		min uint64
+		b   [8]byte
+	)
+	switch marker {
+	case 0xfd:
+		if _, err := io.ReadFull(r, b[:2]); err != nil {
+			return 0, err
+		}
+		n, min = uint64(binary.LittleEndian.Uint16(b[:])), 0xfd
+	case 0xfe:
+		if _, err := io.ReadFull(r, b[:4]); err != nil {
+			return 0, err
+		}
+		n, min = uint64(binary.LittleEndian.Uint32(b[:])), math.MaxUint16+1
+	case 0xff:
+		if _, err := io.ReadFull(r, b[:8]); err != nil {
+			return 0, err
+		}
+		n, min = binary.LittleEndian.Uint64(b[:]), math.MaxUint32+1
+	default:
+		return uint64(marker), nil
+	}
+
+	if n < min {
+		return 0, errNonCanonicalVarInt
+	}
+	return n, nil
+}
+
+func writeBytes(buf *bytes.Buffer, data []byte) {
+	writeVarInt(buf, uint64(len(data)))
+	buf.Write(data)
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := readCount(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readCount reads a VarInt counting items of at least one byte, so it cannot
+// exceed what is left to read.
+func readCount(r *bytes.Reader) (int, error) {
+	n, err := readVarInt(r)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(r.Len()) {
+		return 0, fmt.Errorf("count %d exceeds the %d bytes left", n, r.Len())
+	}
+	return int(n), nil
+}
+
+func writeUint32(buf *bytes.Buffer, n uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], n)
+	buf.Write(b[:])
+}
+
+func writeUint64(buf *bytes.Buffer, n uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], n)
+	buf.Write(b[:])
+}
+
+func readUint32(r *bytes.Reader) (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b[:]), nil
+}
+
+// encode writes the canonical encoding of tx to buf.
+func (tx *Transaction) encode(buf *bytes.Buffer) {
+	writeVarInt(buf, uint64(len(tx.Vin)))
+	for _, vin := range tx.Vin {
+		writeBytes(buf, vin.Txid)
+		writeUint32(buf, uint32(int32(vin.Vout)))
+		writeBytes(buf, vin.ScriptSig)
+		writeUint32(buf, vin.Sequence)
+	}
+
+	writeVarInt(buf, uint64(len(tx.Vout)))
+	for _, vout := range tx.Vout {
+		writeOutput(buf, vout)
+	}
+
+	writeUint32(buf, tx.LockTime)
+}
+
+func writeOutput(buf *bytes.Buffer, out TXOutput) {
+	writeUint64(buf, uint64(int64(out.Value)))
+	writeBytes(buf, out.ScriptPubKey)
+}
+
+// decode reads a transaction encoded by encode. ID is not set.
+func (tx *Transaction) decode(r *bytes.Reader) error {
+	inCount, err := readCount(r)
+	if err != nil {
+		return err
+	}
+	tx.Vin = make([]TXInput, inCount)
+	for i := range tx.Vin {
+		vin := &tx.Vin[i]
+
+		if vin.Txid, err = readBytes(r); err != nil {
+			return err
+		}
+		vout, err := readUint32(r)
+		if err != nil {
+			return err
+		}
+		vin.Vout = int(int32(vout))
+		if vin.ScriptSig, err = readBytes(r); err != nil {
+			return err
+		}
+		if vin.Sequence, err = readUint32(r); err != nil {
+			return err
+		}
+	}
+
+	outCount, err := readCount(r)
+	if err != nil {
+		return err
+	}
+	tx.Vout = make([]TXOutput, outCount)
+	for i := range tx.Vout {
+		vout := &tx.Vout[i]
+
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return err
+		}
+		vout.Value = int(int64(binary.LittleEndian.Uint64(b[:])))
+		if vout.ScriptPubKey, err = readBytes(r); err != nil {
+			return err
+		}
+	}
+
+	tx.LockTime, err = readUint32(r)
+	return err
+}
+
+// Deserialize decodes the canonical encoding of a transaction and computes
+// its ID.
+func (tx *Transaction) Deserialize(data []byte) error {
+	r := bytes.NewReader(data)
+
+	var decoded Transaction
+	if err := decoded.decode(r); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("%d bytes left after transaction", r.Len())
+	}
+
+	decoded.ID = decoded.ComputeID()
+	*tx = decoded
+
+	return nil
+}
+
+// GobEncode encodes the transaction in its canonical encoding, so that
+// blocks store and carry the same bytes.
+func (tx Transaction) GobEncode() ([]byte, error) {
+	return tx.Serialize(), nil
+}
+
+// GobDecode decodes a transaction encoded by GobEncode.
+func (tx *Transaction) GobDecode(data []byte) error {
+	return tx.Deserialize(data)
+}
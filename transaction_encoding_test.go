@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestTransactionEncodingVectors(t *testing.T) {
+	pubKeyHashScript := PayToPubKeyHashScript(bytes.Repeat([]byte{0x22}, 20))
+
+	vectors := []struct {
+		tx      Transaction
+		encoded string
+		wtxid   string
+		id      string
+	}{
+		{
+			tx: Transaction{
+				Vin: []TXInput{{
+					Txid:      bytes.Repeat([]byte{0x11}, 32),
+					Vout:      1,
+					ScriptSig: []byte{0xaa, 0xbb},
+					Sequence:  sequenceLockTimeEnabled,
+				}},
+				Vout: []TXOutput{
+					{Value: 10, ScriptPubKey: pubKeyHashScript},
+					{Value: 0, ScriptPubKey: append([]byte{OP_RETURN, 3}, "doc"...)},
+				},
+				LockTime: 100,
+			},
+			encoded: "01" +
+				"20" + "1111111111111111111111111111111111111111111111111111111111111111" +
+				"01000000" + "02aabb" + "feffffff" +
+				"02" +
+				"0a00000000000000" + "1976a914222222222222222222222222222222222222222288ac" +
+				"0000000000000000" + "056a03646f63" +
+				"64000000",
+			wtxid: "fec4fdac89395e2904740d80e9604d5a441089507a494ce806a55df5df399107",
+			id:    "c5461d422f7e5c4050f068986d74b3c49db7c3f0cea3367b3df2992f2b312fb2",
+		},
+		{
+			tx: Transaction{
+				Vin:  []TXInput{{Txid: []byte{}, Vout: -1, ScriptSig: []byte("Block 1"), Sequence: sequenceFinal}},
+				Vout: []TXOutput{{Value: 10, ScriptPubKey: pubKeyHashScript}},
+			},
+			encoded: "01" +
+				"00" + "ffffffff" + "07426c6f636b2031" + "ffffffff" +
+				"01" +
+				"0a00000000000000" + "1976a914222222222222222222222222222222222222222288ac" +
+				"00000000",
+			wtxid: "8cf90ef23d85f074ea5a6f0191cc68e00cfef57eda785231c24bad0f75fc5e49",
+			id:    "8cf90ef23d85f074ea5a6f0191cc68e00cfef57eda785231c24bad0f75fc5e49",
+		},
+	}
+
+	for i, v := range vectors {
+		assert.Equal(t, v.encoded, hex.EncodeToString(v.tx.Serialize()), "Vector %d encoding.", i)
+		assert.Equal(t, v.wtxid, hex.EncodeToString(v.tx.WitnessID()), "Vector %d wtxid.", i)
+		assert.Equal(t, v.id, hex.EncodeToString(v.tx.ComputeID()), "Vector %d ID.", i)
+
+		decoded := DeserializeTransaction(mustDecodeHex(t, v.encoded))
+		assert.Equal(t, v.id, hex.EncodeToString(decoded.ID), "Vector %d decoded ID.", i)
+		assert.Equal(t, v.encoded, hex.EncodeToString(decoded.Serialize()), "Vector %d round trip.", i)
+	}
+}
+
+func TestTransactionSerializeRoundTrip(t *testing.T) {
+	wallet := NewWallet()
+	tx := NewCoinbaseTX(string(wallet.GetAddress()), "")
+	spending := Transaction{
+		Vin: []TXInput{
+			{Txid: tx.ID, Vout: 0, ScriptSig: bytes.Repeat([]byte{0x01}, 300), Sequence: 10},
+			{Txid: tx.ID, Vout: 70000, Sequence: sequenceFinal},
+		},
+		Vout:     []TXOutput{*NewTXOutput(7, string(wallet.GetAddress())), {Value: -1}},
+		LockTime: 1500000000,
+	}
+	spending.ID = spending.ComputeID()
+
+	for _, original := range []*Transaction{tx, &spending} {
+		var decoded Transaction
+		assert.Nil(t, decoded.Deserialize(original.Serialize()))
+		assert.Equal(t, original.Serialize(), decoded.Serialize())
+		assert.Equal(t, original.ID, decoded.ID)
+		assert.Equal(t, original.IsCoinbase(), decoded.IsCoinbase())
+	}
+
+	// Witnesses change the wtxid but not the ID.
+	signed := spending
+	signed.Vin = append([]TXInput(nil), spending.Vin...)
+	signed.Vin[1].ScriptSig = []byte{0x02}
+	assert.Equal(t, spending.ComputeID(), signed.ComputeID())
+	assert.NotEqual(t, spending.WitnessID(), signed.WitnessID())
+}
+
+func TestTransactionDeserializeRejects(t *testing.T) {
+	valid := "0100ffffffff00ffffffff0000000000"
+	var tx Transaction
+	assert.Nil(t, tx.Deserialize(mustDecodeHex(t, valid)))
+
+	for _, encoded := range []string{
+		"",
+		valid[:len(valid)-2],
+		valid + "00",
+		"fd0100" + valid[2:],
+		"ff" + valid,
+	} {
+		assert.NotNil(t, tx.Deserialize(mustDecodeHex(t, encoded)), "Encoding %q.", encoded)
+	}
+}
+
+func TestVarInt(t *testing.T) {
+	for n, encoded := range map[uint64]string{
+		0:          "00",
+		0xfc:       "fc",
+		0xfd:       "fdfd00",
+		0xffff:     "fdffff",
+		0x10000:    "fe00000100",
+		0xffffffff: "feffffffff",
+		1 << 32:    "ff0000000001000000",
+	} {
+		var buf bytes.Buffer
+		writeVarInt(&buf, n)
+		assert.Equal(t, encoded, hex.EncodeToString(buf.Bytes()))
+
+		decoded, err := readVarInt(bytes.NewReader(buf.Bytes()))
+		assert.Nil(t, err)
+		assert.Equal(t, n, decoded)
+	}
+
+	_, err := readVarInt(bytes.NewReader(mustDecodeHex(t, "fefc000000")))
+	assert.NotNil(t, err, "Non-minimal varints are rejected.")
+}
@@ -3,15 +3,25 @@ package main
 import "bytes"
 
 // TXInput represents a transaction input.
+// ScriptSig unlocks the output it spends, it usually holds a signature and
+// the public key matching the locking script. It is the witness of the
+// input: the txid leaves it out, the wtxid covers it.
+// Sequence enables the lock time of the transaction unless it is
+// sequenceFinal, and may hold a relative lock on the output spent.
 type TXInput struct {
 	Txid      []byte
 	Vout      int
-	Signature []byte
-	PubKey    []byte
+	ScriptSig []byte
+	Sequence  uint32
 }
 
 // UsesKey checks whether the address initiated the transaction.
 func (in *TXInput) UsesKey(pubKeyHash []byte) bool {
-	lockingHash := HashPubKey(in.PubKey)
+	data, err := pushedData(in.ScriptSig)
+	if err != nil || len(data) != 2 {
+		return false
+	}
+
+	lockingHash := HashPubKey(data[1])
 	return bytes.Compare(lockingHash, pubKeyHash) == 0
 }
@@ -3,37 +3,59 @@ package main
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"log"
 )
 
+var errLegacyUTXOSet = errors.New("error: the UTXO set has no output indexes, run reindexutxo")
+
 // TXOutput represents a transaction output.
+// ScriptPubKey is the locking script an input spending the output has to
+// satisfy.
 type TXOutput struct {
-	Value      int
-	PubKeyHash []byte
+	Value        int
+	ScriptPubKey []byte
 }
 
-// Lock signs the output.
+// Lock locks the output to an address, a Base58 or a Bech32 address.
 func (out *TXOutput) Lock(address []byte) {
-	pubKeyHash := Base58Decode(address)
-	out.PubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+	script, err := PayToAddrScript(string(address))
+	if err != nil {
+		log.Panic(err)
+	}
+	out.ScriptPubKey = script
 }
 
 // IsLockedWithKey checks if the output can be used by the owner of the pubkey.
 func (out *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
-	return bytes.Compare(out.PubKeyHash, pubKeyHash) == 0
+	return bytes.Compare(extractPubKeyHash(scriptCode(out.ScriptPubKey)), pubKeyHash) == 0
 }
 
 // NewTXOutput create a new TXOutput.
 func NewTXOutput(value int, address string) *TXOutput {
-	txo := &TXOutput{Value: value, PubKeyHash: nil}
+	txo := &TXOutput{Value: value, ScriptPubKey: nil}
 	txo.Lock([]byte(address))
 
 	return txo
 }
 
 // TXOutputs collects TXOutput.
+// In the UTXO set it holds the unspent outputs of a transaction, and Indexes
+// their index in the transaction. Height and Time are the height and the
+// timestamp of the block including the transaction and Coinbase tells
+// whether it is a coinbase. Entries stored before heights were recorded have
+// a zero Height and Time until reindexutxo.
 type TXOutputs struct {
-	Outputs []TXOutput
+	Outputs  []TXOutput
+	Indexes  []int
+	Height   int
+	Time     int64
+	Coinbase bool
+}
+
+// Index returns the index in its transaction of the i-th output.
+func (outs TXOutputs) Index(i int) int {
+	return outs.Indexes[i]
 }
 
 // Serialize serializes TXOutputs.
@@ -50,6 +72,8 @@ func (outs TXOutputs) Serialize() []byte {
 }
 
 // DeserializeOutputs deserializes TXOutputs.
+// Entries stored before indexes were recorded are rejected: spent outputs
+// were removed from them, so the index of the others is not known.
 func DeserializeOutputs(data []byte) TXOutputs {
 	var outputs TXOutputs
 
@@ -58,6 +82,9 @@ func DeserializeOutputs(data []byte) TXOutputs {
 	if err != nil {
 		log.Panic(err)
 	}
+	if len(outputs.Indexes) != len(outputs.Outputs) {
+		log.Panic(errLegacyUTXOSet)
+	}
 
 	return outputs
 }
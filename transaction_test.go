@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignWithWallets(t *testing.T) {
+	first, second := NewWallet(), NewWallet()
+	wallets := &Wallets{Wallets: map[string]*Wallet{}}
+	wallets.addWallet(first)
+	wallets.addWallet(second)
+
+	prevTx := Transaction{
+		ID: []byte{0x01},
+		Vout: []TXOutput{
+			{Value: 10, ScriptPubKey: PayToPubKeyHashScript(HashPubKey(first.PublicKey))},
+			{Value: 5, ScriptPubKey: PayToPubKeyHashScript(HashPubKey(second.PublicKey))},
+			{Value: 1, ScriptPubKey: PayToPubKeyHashScript(HashPubKey(NewWallet().PublicKey))},
+		},
+	}
+	prevTXs := map[string]Transaction{"01": prevTx}
+	tx := &Transaction{
+		Vin: []TXInput{
+			{Txid: prevTx.ID, Vout: 1, Sequence: sequenceFinal},
+			{Txid: prevTx.ID, Vout: 0, Sequence: sequenceFinal},
+		},
+		Vout: []TXOutput{*NewTXOutput(15, string(first.GetAddress()))},
+	}
+
+	assert.Nil(t, tx.SignWithWallets(wallets, prevTXs))
+	assert.True(t, tx.Verify(prevTXs), "Each input is signed by its own key.")
+	assert.True(t, tx.Vin[0].UsesKey(HashPubKey(second.PublicKey)))
+	assert.True(t, tx.Vin[1].UsesKey(HashPubKey(first.PublicKey)))
+
+	tx.Vin = append(tx.Vin, TXInput{Txid: prevTx.ID, Vout: 2, Sequence: sequenceFinal})
+	assert.NotNil(t, tx.SignWithWallets(wallets, prevTXs), "The wallet file has no key for input 2.")
+}
+
+func TestNewDataTransaction(t *testing.T) {
+	activeNet = &RegTestParams
+	defer func() { activeNet = &MainNetParams }()
+
+	alice := NewWallet()
+	wallets := &Wallets{Wallets: map[string]*Wallet{}}
+	from := wallets.addWallet(alice)
+	bc := testBlockchain(t, string(alice.GetBech32Address()))
+	UTXOSet := UTXOSet{bc}
+	reward := bc.Iterator().Next().Transactions[0]
+
+	assert.Equal(t, from, fundingAddress(wallets, &UTXOSet), "Bech32 outputs fund anchors.")
+
+	tx := NewDataTransaction(alice, []byte("document hash"), &UTXOSet)
+	if assert.Len(t, tx.Vin, 1) {
+		assert.Equal(t, reward.ID, tx.Vin[0].Txid)
+	}
+	if assert.Len(t, tx.Vout, 2) {
+		assert.Equal(t, *NewTXOutput(activeNet.Subsidy, from), tx.Vout[1], "The value goes back to the Base58 address.")
+	}
+	assert.True(t, bc.VerifyTransaction(tx))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/hex"
 	"log"
 
@@ -14,21 +15,23 @@ type UTXOSet struct {
 	Blockchain *Blockchain
 }
 
-// Reindex rebuilds the UTXO set.
+// Reindex rebuilds the UTXO set and the index of anchored data.
 func (u UTXOSet) Reindex() {
 	db := u.Blockchain.db
 	// bucketName := []byte(utxoBucket)
 
 	err := db.Update(func(tx *bolt.Tx) error {
-		// Removes the bucket if it exists.
-		err := tx.DeleteBucket([]byte(utxoBucket))
-		if err != nil && err != bolt.ErrBucketNotFound {
-			log.Panic(err)
-		}
+		// Removes the buckets if they exist.
+		for _, bucket := range []string{utxoBucket, anchorsBucket} {
+			err := tx.DeleteBucket([]byte(bucket))
+			if err != nil && err != bolt.ErrBucketNotFound {
+				log.Panic(err)
+			}
 
-		_, err = tx.CreateBucket([]byte(utxoBucket))
-		if err != nil {
-			log.Panic(err)
+			_, err = tx.CreateBucket([]byte(bucket))
+			if err != nil {
+				log.Panic(err)
+			}
 		}
 		return nil
 	})
@@ -37,9 +40,15 @@ func (u UTXOSet) Reindex() {
 	}
 
 	UTXO := u.Blockchain.FindUTXO()
+	anchors := u.Blockchain.FindAnchors()
 
 	err = db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
+		ab := tx.Bucket([]byte(anchorsBucket))
+
+		for _, anchor := range anchors {
+			putAnchor(ab, anchor)
+		}
 
 		for txID, outs := range UTXO {
 			key, err := hex.DecodeString(txID)
@@ -60,8 +69,9 @@ func (u UTXOSet) Reindex() {
 	}
 }
 
-// FindSpendableOutputs finds and returns unspent outputs to reference in inputs.
-func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int) {
+// FindSpendableOutputs finds and returns unspent outputs locked by
+// scriptPubKey to reference in inputs.
+func (u UTXOSet) FindSpendableOutputs(scriptPubKey []byte, amount int) (int, map[string][]int) {
 	unspentOutputs := make(map[string][]int)
 	accumulated := 0
 	db := u.Blockchain.db
@@ -74,10 +84,10 @@ func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[s
 			txID := hex.EncodeToString(k)
 			outs := DeserializeOutputs(v)
 
-			for outIdx, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubKeyHash) && accumulated < amount {
+			for i, out := range outs.Outputs {
+				if bytes.Equal(out.ScriptPubKey, scriptPubKey) && accumulated < amount {
 					accumulated += out.Value
-					unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
+					unspentOutputs[txID] = append(unspentOutputs[txID], outs.Index(i))
 				}
 			}
 		}
@@ -91,8 +101,75 @@ func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[s
 	return accumulated, unspentOutputs
 }
 
-// FindUTXO finds UTXO for a public key hash.
-func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
+// FindCoins finds the unspent outputs locked by any of scriptPubKeys along
+// with their outpoints.
+func (u UTXOSet) FindCoins(scriptPubKeys ...[]byte) []Coin {
+	var coins []Coin
+	db := u.Blockchain.db
+
+	lockScripts := make(map[string]bool)
+	for _, scriptPubKey := range scriptPubKeys {
+		lockScripts[string(scriptPubKey)] = true
+	}
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			outs := DeserializeOutputs(v)
+
+			for i, out := range outs.Outputs {
+				if lockScripts[string(out.ScriptPubKey)] {
+					txID := append([]byte(nil), k...)
+					coins = append(coins, Coin{OutPoint{txID, outs.Index(i)}, out})
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return coins
+}
+
+// FindCoin finds the unspent output of outPoint. It reports false if the
+// output does not exist or was spent.
+func (u UTXOSet) FindCoin(outPoint OutPoint) (Coin, bool) {
+	var (
+		coin  Coin
+		found bool
+	)
+	db := u.Blockchain.db
+
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		outsBytes := b.Get(outPoint.Txid)
+		if outsBytes == nil {
+			return nil
+		}
+
+		outs := DeserializeOutputs(outsBytes)
+		for i, out := range outs.Outputs {
+			if outs.Index(i) == outPoint.Vout {
+				coin, found = Coin{outPoint, out}, true
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return coin, found
+}
+
+// FindUTXO finds UTXO locked by scriptPubKey.
+func (u UTXOSet) FindUTXO(scriptPubKey []byte) []TXOutput {
 	var UTXOs []TXOutput
 	db := u.Blockchain.db
 
@@ -104,7 +181,7 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
 			outs := DeserializeOutputs(v)
 
 			for _, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubKeyHash) {
+				if bytes.Equal(out.ScriptPubKey, scriptPubKey) {
 					UTXOs = append(UTXOs, out)
 				}
 			}
@@ -121,22 +198,29 @@ func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TXOutput {
 
 // Update updates the UTXO set with transactions from the Block.
 // The Block is considered to be the tip of a blockchain.
+// Unspendable outputs are not added to the set, the data they carry is
+// indexed instead.
 func (u UTXOSet) Update(block *Block) {
 	db := u.Blockchain.db
 
 	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
+		ab, err := tx.CreateBucketIfNotExists([]byte(anchorsBucket))
+		if err != nil {
+			log.Panic(err)
+		}
 
 		for _, tx := range block.Transactions {
 			if tx.IsCoinbase() == false {
 				for _, vin := range tx.Vin {
-					updatedOuts := TXOutputs{}
 					outsBytes := b.Get(vin.Txid)
 					outs := DeserializeOutputs(outsBytes)
+					updatedOuts := TXOutputs{Height: outs.Height, Time: outs.Time, Coinbase: outs.Coinbase}
 
-					for outIdx, out := range outs.Outputs {
-						if outIdx != vin.Vout {
+					for i, out := range outs.Outputs {
+						if outs.Index(i) != vin.Vout {
 							updatedOuts.Outputs = append(updatedOuts.Outputs, out)
+							updatedOuts.Indexes = append(updatedOuts.Indexes, outs.Index(i))
 						}
 					}
 
@@ -154,9 +238,16 @@ func (u UTXOSet) Update(block *Block) {
 				}
 			}
 
-			newOutputs := TXOutputs{}
-			for _, out := range tx.Vout {
+			newOutputs := TXOutputs{Height: block.Height, Time: block.Timestamp, Coinbase: tx.IsCoinbase()}
+			for outIdx, out := range tx.Vout {
+				if isUnspendable(out.ScriptPubKey) {
+					continue
+				}
 				newOutputs.Outputs = append(newOutputs.Outputs, out)
+				newOutputs.Indexes = append(newOutputs.Indexes, outIdx)
+			}
+			if len(newOutputs.Outputs) == 0 {
+				continue
 			}
 
 			err := b.Put(tx.ID, newOutputs.Serialize())
@@ -165,6 +256,10 @@ func (u UTXOSet) Update(block *Block) {
 			}
 		}
 
+		for _, anchor := range blockAnchors(block) {
+			putAnchor(ab, anchor)
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUTXOSetSkipsDataOutputs(t *testing.T) {
+	UTXOSet := testUTXOSet(t)
+
+	lockScript := PayToPubKeyHashScript(HashPubKey(NewWallet().PublicKey))
+	coinbase := &Transaction{
+		ID:   []byte{0x01},
+		Vin:  []TXInput{{Txid: []byte{}, Vout: -1}},
+		Vout: []TXOutput{{Value: 10, ScriptPubKey: lockScript}},
+	}
+	UTXOSet.Update(&Block{Hash: []byte{0xb1}, Height: 1, Transactions: []*Transaction{coinbase}})
+
+	document := []byte("document hash")
+	dataScript, err := NullDataScript(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anchoring := &Transaction{
+		ID:  []byte{0x02},
+		Vin: []TXInput{{Txid: coinbase.ID, Vout: 0}},
+		Vout: []TXOutput{
+			{Value: 0, ScriptPubKey: dataScript},
+			{Value: 10, ScriptPubKey: lockScript},
+		},
+	}
+	UTXOSet.Update(&Block{Hash: []byte{0xb2}, Height: 2, Timestamp: 1500000000, Transactions: []*Transaction{anchoring}})
+
+	assert.Equal(t, []TXOutput{anchoring.Vout[1]}, UTXOSet.FindUTXO(lockScript))
+	assert.Empty(t, UTXOSet.FindUTXO(dataScript), "Data outputs are not in the chainstate.")
+
+	acc, outputs := UTXOSet.FindSpendableOutputs(lockScript, 10)
+	assert.Equal(t, 10, acc)
+	assert.Equal(t, map[string][]int{hex.EncodeToString(anchoring.ID): {1}}, outputs, "Outputs keep their index in the transaction.")
+
+	anchor, err := UTXOSet.FindAnchor(document)
+	assert.Nil(t, err)
+	assert.Equal(t, anchoring.ID, anchor.TxID)
+	assert.Equal(t, 2, anchor.Height)
+	assert.Equal(t, int64(1500000000), anchor.Timestamp)
+
+	_, err = UTXOSet.FindAnchor([]byte("unknown"))
+	assert.Equal(t, errAnchorNotFound, err)
+
+	legacy := TXOutputs{Outputs: []TXOutput{anchoring.Vout[1]}}
+	assert.PanicsWithValue(t, errLegacyUTXOSet.Error(), func() { DeserializeOutputs(legacy.Serialize()) },
+		"Entries without indexes require reindexutxo.")
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// VanitySearch looks for a key whose address starts with a prefix, by
+// generating keys on every core until one matches.
+type VanitySearch struct {
+	Prefix     string
+	IgnoreCase bool
+	// Workers is the number of goroutines generating keys, the number of
+	// CPUs if 0.
+	Workers int
+	// ProgressInterval is the time between calls to the progress function
+	// of Run.
+	ProgressInterval time.Duration
+
+	// difficulty is the expected number of keys to generate.
+	difficulty float64
+}
+
+// NewVanitySearch returns a search for prefix, checking that addresses of
+// the active network can start with it.
+func NewVanitySearch(prefix string, ignoreCase bool) (*VanitySearch, error) {
+	if prefix == "" {
+		return nil, errors.New("error: empty prefix")
+	}
+
+	// Each spelling of the prefix matches its own addresses.
+	spellings := []string{""}
+	for _, c := range prefix {
+		variants := []string{string(c)}
+		if ignoreCase {
+			variants = []string{strings.ToLower(string(c)), strings.ToUpper(string(c))}
+			if variants[0] == variants[1] {
+				variants = variants[:1]
+			}
+		}
+
+		var next []string
+		for _, variant := range variants {
+			if !isBase58(variant) {
+				continue
+			}
+			for _, spelling := range spellings {
+				next = append(next, spelling+variant)
+			}
+		}
+		if len(next) == 0 {
+			return nil, fmt.Errorf("error: %q is not a Base58 character", c)
+		}
+		spellings = next
+	}
+
+	probability := new(big.Rat)
+	for _, spelling := range spellings {
+		probability.Add(probability, prefixProbability(spelling))
+	}
+	if probability.Sign() == 0 {
+		return nil, fmt.Errorf("error: no %s address starts with %s", activeNet.Name, prefix)
+	}
+	difficulty, _ := new(big.Rat).Inv(probability).Float64()
+
+	return &VanitySearch{
+		Prefix:           prefix,
+		IgnoreCase:       ignoreCase,
+		ProgressInterval: 5 * time.Second,
+		difficulty:       difficulty,
+	}, nil
+}
+
+// prefixProbability returns the share of the addresses of the active network
+// starting with prefix.
+// An address encodes the number made of its version byte, hash and
+// checksum. A version byte of 0 is encoded as a leading 1, and so is each
+// zero byte starting the hash. The rest of the address encodes the number
+// made of the bytes after them.
+func prefixProbability(prefix string) *big.Rat {
+	payloadLen := addressHashLen + addressChecksumLen
+	version := big.NewInt(int64(activeNet.AddressVersion))
+	low := new(big.Int).Lsh(version, uint(8*payloadLen))
+	high := new(big.Int).Lsh(new(big.Int).Add(version, big.NewInt(1)), uint(8*payloadLen))
+	total := new(big.Int).Sub(high, low)
+	if activeNet.AddressVersion == 0 {
+		if prefix[0] != b58Alphabet[0] {
+			return new(big.Rat)
+		}
+		zeros := 0
+		for zeros+1 < len(prefix) && prefix[zeros+1] == b58Alphabet[0] {
+			zeros++
+		}
+		prefix = prefix[zeros+1:]
+		if zeros > payloadLen || zeros == payloadLen && prefix != "" {
+			return new(big.Rat)
+		}
+		if prefix == "" {
+			return new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), uint(8*zeros)))
+		}
+
+		// The payload starts with exactly zeros zero bytes.
+		low = new(big.Int).Lsh(big.NewInt(1), uint(8*(payloadLen-zeros-1)))
+		high = new(big.Int).Lsh(big.NewInt(1), uint(8*(payloadLen-zeros)))
+	}
+	if prefix == "" {
+		return big.NewRat(1, 1)
+	}
+
+	// The numbers of L digits starting with prefix.
+	base := big.NewInt(int64(len(b58Alphabet)))
+	value := new(big.Int)
+	for _, c := range []byte(prefix) {
+		value.Mul(value, base)
+		value.Add(value, big.NewInt(int64(bytes.IndexByte(b58Alphabet, c))))
+	}
+	count := new(big.Int)
+	scale := big.NewInt(1)
+	smallest := new(big.Int).Exp(base, big.NewInt(int64(len(prefix)-1)), nil)
+	for smallest.Cmp(high) < 0 {
+		from := maxInt(new(big.Int).Mul(value, scale), smallest, low)
+		to := minInt(new(big.Int).Mul(new(big.Int).Add(value, big.NewInt(1)), scale), new(big.Int).Mul(smallest, base), high)
+		if to.Cmp(from) > 0 {
+			count.Add(count, new(big.Int).Sub(to, from))
+		}
+
+		scale.Mul(scale, base)
+		smallest.Mul(smallest, base)
+	}
+
+	return new(big.Rat).SetFrac(count, total)
+}
+
+func maxInt(x *big.Int, ys ...*big.Int) *big.Int {
+	for _, y := range ys {
+		if y.Cmp(x) > 0 {
+			x = y
+		}
+	}
+	return x
+}
+
+func minInt(x *big.Int, ys ...*big.Int) *big.Int {
+	for _, y := range ys {
+		if y.Cmp(x) < 0 {
+			x = y
+		}
+	}
+	return x
+}
+
+// Match checks whether address starts with the prefix.
+func (s *VanitySearch) Match(address string) bool {
+	if len(address) < len(s.Prefix) {
+		return false
+	}
+	if s.IgnoreCase {
+		return strings.EqualFold(address[:len(s.Prefix)], s.Prefix)
+	}
+	return address[:len(s.Prefix)] == s.Prefix
+}
+
+// Difficulty estimates the number of keys to generate to find a match.
+func (s *VanitySearch) Difficulty() float64 {
+	return s.difficulty
+}
+
+// Run generates keys until the address of one matches and returns its
+// wallet. If progress is not nil, it is called every ProgressInterval with
+// the number of keys generated so far.
+func (s *VanitySearch) Run(progress func(attempts uint64)) *Wallet {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var (
+		attempts uint64
+		found    = make(chan *Wallet, 1)
+		stop     = make(chan struct{})
+		wg       sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				wallet := NewWallet()
+				atomic.AddUint64(&attempts, 1)
+				if s.Match(string(wallet.GetAddress())) {
+					select {
+					case found <- wallet:
+					default:
+					}
+					return
+				}
+			}
+		}()
+	}
+
+	interval := s.ProgressInterval
+	if progress == nil || interval <= 0 {
+		interval = math.MaxInt64
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case wallet := <-found:
+			close(stop)
+			wg.Wait()
+			return wallet
+		case <-ticker.C:
+			progress(atomic.LoadUint64(&attempts))
+		}
+	}
+}
+
+// isBase58 checks whether c is a character of the Base58 alphabet.
+func isBase58(c string) bool {
+	return len(c) == 1 && bytes.IndexByte(b58Alphabet, c[0]) >= 0
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVanitySearch(t *testing.T) {
+	activeNet = &RegTestParams
+	defer func() { activeNet = &MainNetParams }()
+
+	search, err := NewVanitySearch("mz", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	search.Workers, search.ProgressInterval = 2, time.Millisecond
+	wallet := search.Run(func(attempts uint64) {})
+	assert.True(t, strings.HasPrefix(string(wallet.GetAddress()), "mz"))
+
+	ignoreCase, err := NewVanitySearch("MZ", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, search.Difficulty(), ignoreCase.Difficulty(), "No regtest address starts with mZ, Mz or MZ.")
+	assert.True(t, ignoreCase.Match("mZabc"))
+	assert.False(t, ignoreCase.Match("m"))
+	assert.True(t, strings.HasPrefix(strings.ToLower(string(ignoreCase.Run(nil).GetAddress())), "mz"))
+
+	// Regtest addresses range from mfWxJ... to n4... .
+	for _, prefix := range []string{"", "1Team", "m0", "mI", "me", "na", "n5"} {
+		_, err := NewVanitySearch(prefix, false)
+		assert.NotNil(t, err, "Prefix %q.", prefix)
+	}
+	_, err = NewVanitySearch("mi", true)
+	assert.Nil(t, err, "i is a Base58 character.")
+
+	activeNet = &MainNetParams
+	search, err = NewVanitySearch("1Team", false)
+	assert.Nil(t, err)
+	assert.InEpsilon(t, 264104224, search.Difficulty(), 0.01)
+	search, err = NewVanitySearch("1", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0, search.Difficulty())
+
+	// Further leading 1s encode zero bytes starting the hash.
+	search, err = NewVanitySearch("11", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 256.0, search.Difficulty())
+	search, err = NewVanitySearch("111", false)
+	assert.Nil(t, err)
+	assert.Equal(t, 65536.0, search.Difficulty())
+	search, err = NewVanitySearch("11Team", false)
+	assert.Nil(t, err)
+	assert.InEpsilon(t, 15318045009, search.Difficulty(), 0.01)
+}
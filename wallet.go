@@ -4,35 +4,79 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
+	"encoding/gob"
 	"log"
 
 	"golang.org/x/crypto/ripemd160"
 )
 
 const (
-	version            = byte(0x00)
 	addressChecksumLen = 4
 )
 
 // Wallet stores private and public keys.
+// When the wallet is encrypted, the private key is kept sealed in EncryptedKey
+// and PrivateKey.D is only set while the wallet is unlocked.
 type Wallet struct {
+	PrivateKey   ecdsa.PrivateKey
+	PublicKey    []byte
+	EncryptedKey []byte
+}
+
+// walletData is the stored form of a Wallet.
+type walletData struct {
+	Curve        string
+	PrivateKey   []byte
+	PublicKey    []byte
+	EncryptedKey []byte
+}
+
+// legacyWallet is a Wallet as stored before walletData: the gob encoding of
+// a P-256 key pair, with the public key uncompressed.
+type legacyWallet struct {
 	PrivateKey ecdsa.PrivateKey
 	PublicKey  []byte
 }
 
+// legacyP256 decodes the curve of legacy wallets, stored under the name Go
+// registered the P-256 curve with.
+type legacyP256 struct {
+	*elliptic.CurveParams
+}
+
+func init() {
+	gob.RegisterName("crypto/elliptic.p256Curve", legacyP256{})
+}
+
 // GetAddress returns wallet address.
 func (w Wallet) GetAddress() []byte {
 	pubKeyHash := HashPubKey(w.PublicKey)
 
-	versionedPayload := append([]byte{version}, pubKeyHash...)
-	checksum := checksum(versionedPayload)
+	return []byte(encodeAddress(activeNet.AddressVersion, pubKeyHash))
+}
 
-	fullPayload := append(versionedPayload, checksum...)
-	address := Base58Encode(fullPayload)
+// GetBech32Address returns the Bech32 wallet address, paying to the version 0
+// witness program of the public key hash.
+func (w Wallet) GetBech32Address() []byte {
+	address, err := encodeWitnessAddress(activeNet.Bech32HRP, 0, HashPubKey(w.PublicKey))
+	if err != nil {
+		log.Panic(err)
+	}
 
-	return address
+	return []byte(address)
+}
+
+// LockScripts returns the locking scripts of the outputs the key of the
+// wallet spends: paying to its Base58 address and to its Bech32 address.
+func (w Wallet) LockScripts() [][]byte {
+	return pubKeyHashLockScripts(HashPubKey(w.PublicKey))
+}
+
+// pubKeyHashLockScripts returns the locking scripts of the outputs spent by
+// the key hashing to pubKeyHash.
+func pubKeyHashLockScripts(pubKeyHash []byte) [][]byte {
+	return [][]byte{PayToPubKeyHashScript(pubKeyHash), PayToWitnessPubKeyHashScript(pubKeyHash)}
 }
 
 // NewWallet creates and returns a Wallet.
@@ -45,14 +89,95 @@ func NewWallet() *Wallet {
 	return &wallet
 }
 
+// newKeyPair generates a key pair on the curve of the active network.
 func newKeyPair() (ecdsa.PrivateKey, []byte) {
-	curve := elliptic.P256()
-	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+	private, err := activeNet.Curve.GenerateKey()
 	if err != nil {
 		log.Panic(err)
 	}
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
-	return *private, pubKey
+	return private, encodePubKey(&private.PublicKey)
+}
+
+// encodePubKey returns the compressed public key stored in wallets and
+// inputs.
+func encodePubKey(pub *ecdsa.PublicKey) []byte {
+	return curveOf(pub.Curve).SerializePubKey(pub)
+}
+
+// curve returns the curve of the wallet keys.
+func (w Wallet) curve() Curve {
+	return curveOf(w.PrivateKey.Curve)
+}
+
+// GobEncode encodes the wallet. The private key is left out once it has been
+// encrypted.
+func (w Wallet) GobEncode() ([]byte, error) {
+	data := walletData{
+		Curve:        w.curve().Name(),
+		PublicKey:    w.PublicKey,
+		EncryptedKey: w.EncryptedKey,
+	}
+	if w.EncryptedKey == nil {
+		data.PrivateKey = w.PrivateKey.D.FillBytes(make([]byte, privKeyLen))
+	}
+
+	var buff bytes.Buffer
+	err := gob.NewEncoder(&buff).Encode(data)
+	return buff.Bytes(), err
+}
+
+// GobDecode decodes a wallet encoded by GobEncode.
+func (w *Wallet) GobDecode(b []byte) error {
+	var data walletData
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&data)
+	if err != nil {
+		return err
+	}
+
+	curve, err := curveByName(data.Curve)
+	if err != nil {
+		return err
+	}
+
+	w.PublicKey = data.PublicKey
+	w.EncryptedKey = data.EncryptedKey
+	w.PrivateKey = ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve.Elliptic()}}
+	if data.PrivateKey != nil {
+		w.PrivateKey = curve.PrivateKeyFromBytes(data.PrivateKey)
+	}
+
+	return nil
+}
+
+// isLegacyWalletFile reports whether content is a wallet file of legacy
+// wallets.
+func isLegacyWalletFile(content []byte) bool {
+	var legacy struct {
+		Wallets map[string]*legacyWallet
+	}
+	return gob.NewDecoder(bytes.NewReader(content)).Decode(&legacy) == nil
+}
+
+// encrypt seals the private key with the wallets master key.
+func (w *Wallet) encrypt(key []byte) error {
+	encrypted, err := seal(key, w.PrivateKey.D.FillBytes(make([]byte, privKeyLen)), w.PublicKey)
+	if err != nil {
+		return err
+	}
+	w.EncryptedKey = encrypted
+
+	return nil
+}
+
+// decrypt restores the private key sealed with the wallets master key.
+func (w *Wallet) decrypt(key []byte) error {
+	d, err := open(key, w.EncryptedKey, w.PublicKey)
+	if err != nil {
+		return err
+	}
+	w.PrivateKey = w.curve().PrivateKeyFromBytes(d)
+
+	return nil
 }
 
 // HashPubKey hashes public key.
@@ -75,13 +200,10 @@ func checksum(payload []byte) []byte {
 	return secondSHA[:addressChecksumLen]
 }
 
-// ValidateAddr checks whether address is valid.
+// ValidateAddr checks whether address is valid on the active network. It may
+// be a Base58 address paying to a public key hash or to a script hash, or a
+// Bech32 address paying to a witness program.
 func ValidateAddr(address string) bool {
-	pubKeyHash := Base58Decode([]byte(address))
-	actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
-	version := pubKeyHash[0]
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
-	targetChecksum := checksum(append([]byte{version}, pubKeyHash...))
-
-	return bytes.Compare(actualChecksum, targetChecksum) == 0
+	_, err := PayToAddrScript(address)
+	return err == nil
 }
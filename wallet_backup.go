@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"sort"
+)
+
+// BackupWallet copies the wallet file of the node to path. The copy is only
+// readable by its owner, and as encrypted as the wallet file.
+func BackupWallet(nodeID, path string) error {
+	content, err := ioutil.ReadFile(walletFileName(nodeID))
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, content, 0600)
+}
+
+// LoadBackup loads the wallets backed up to path.
+func LoadBackup(path string) (*Wallets, error) {
+	backup := &Wallets{Wallets: make(map[string]*Wallet)}
+	err := backup.loadFile(path)
+
+	return backup, err
+}
+
+// ImportWallets adds the keys and watched addresses of backup the wallets
+// lack. backup has to be unlocked if it is encrypted, and so do the wallets.
+// The seed of backup is not imported, only the keys it derived so far.
+// It returns the addresses added, sorted.
+func (ws *Wallets) ImportWallets(backup *Wallets) ([]string, error) {
+	if ws.IsLocked() || backup.IsLocked() {
+		return nil, errWalletLocked
+	}
+
+	var imported []string
+	for address, wallet := range backup.Wallets {
+		if _, ok := ws.Wallets[address]; ok {
+			continue
+		}
+		// The key is sealed again with the master key of the wallets.
+		ws.importMetadata(address, backup)
+		ws.addWallet(&Wallet{PrivateKey: wallet.PrivateKey, PublicKey: wallet.PublicKey})
+		delete(ws.WatchOnly, address)
+		imported = append(imported, address)
+	}
+	for address, pubKey := range backup.WatchOnly {
+		if _, ok := ws.Wallets[address]; ok || ws.WatchOnly[address] != nil {
+			continue
+		}
+		if !ws.IsWatchOnly(address) {
+			imported = append(imported, address)
+		}
+		if ws.WatchOnly == nil {
+			ws.WatchOnly = make(map[string][]byte)
+		}
+		ws.WatchOnly[address] = pubKey
+		ws.importMetadata(address, backup)
+	}
+	sort.Strings(imported)
+
+	return imported, nil
+}
+
+// importMetadata copies the metadata of address from backup, unless the
+// wallets have some already.
+func (ws *Wallets) importMetadata(address string, backup *Wallets) {
+	if backup.Metadata[address] == nil {
+		ws.addMetadata(address, false)
+		return
+	}
+	if ws.Metadata == nil {
+		ws.Metadata = make(map[string]*AddressMetadata)
+	}
+	if ws.Metadata[address] == nil {
+		metadata := *backup.Metadata[address]
+		ws.Metadata[address] = &metadata
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt cost parameters used when deriving a key from a passphrase.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	masterKeyLen = 32
+	saltLen      = 16
+
+	// unlockSessionTimeout bounds how long a command may take to talk to
+	// walletpassphrase.
+	unlockSessionTimeout = 5 * time.Second
+)
+
+var (
+	errWalletLocked        = errors.New("wallet is locked, unlock it with walletpassphrase first")
+	errWalletNotEncrypted  = errors.New("wallet is not encrypted")
+	errWalletEncrypted     = errors.New("wallet is already encrypted")
+	errIncorrectPassphrase = errors.New("the passphrase entered was incorrect")
+)
+
+// masterKey is the random key sealing the private keys of an encrypted
+// wallet. It is itself sealed with a key derived from the passphrase, so the
+// passphrase can be changed without re-encrypting every key.
+type masterKey struct {
+	Salt         []byte
+	N, R, P      int
+	EncryptedKey []byte
+}
+
+// unlockSession is what walletpassphrase hands to the commands run while the
+// wallets are unlocked, so that they can sign.
+type unlockSession struct {
+	Key []byte
+}
+
+// unlockRequest is sent to walletpassphrase. It answers with the unlocked
+// master key, or locks the wallets if Lock is set.
+type unlockRequest struct {
+	Lock bool
+}
+
+// newMasterKey generates a master key and seals it with passphrase.
+func newMasterKey(passphrase string) (*masterKey, []byte, error) {
+	key := make([]byte, masterKeyLen)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, nil, err
+	}
+
+	mk, err := sealMasterKey(key, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return mk, key, nil
+}
+
+// sealMasterKey seals key with a key derived from passphrase and a fresh
+// salt.
+func sealMasterKey(key []byte, passphrase string) (*masterKey, error) {
+	mk := &masterKey{
+		Salt: make([]byte, saltLen),
+		N:    scryptN,
+		R:    scryptR,
+		P:    scryptP,
+	}
+	if _, err := io.ReadFull(rand.Reader, mk.Salt); err != nil {
+		return nil, err
+	}
+
+	derived, err := mk.derive(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	mk.EncryptedKey, err = seal(derived, key, mk.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return mk, nil
+}
+
+// derive derives the key sealing the master key from passphrase.
+func (mk *masterKey) derive(passphrase string) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), mk.Salt, mk.N, mk.R, mk.P, masterKeyLen)
+}
+
+// open returns the master key if passphrase is correct.
+func (mk *masterKey) open(passphrase string) ([]byte, error) {
+	derived, err := mk.derive(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := open(derived, mk.EncryptedKey, mk.Salt)
+	if err != nil {
+		return nil, errIncorrectPassphrase
+	}
+
+	return key, nil
+}
+
+// seal encrypts and authenticates plaintext with AES-GCM. The additional data
+// is authenticated but not encrypted. The random nonce is prepended to the
+// result.
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts data sealed by seal.
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// IsEncrypted checks whether private keys of the wallets are encrypted.
+func (ws *Wallets) IsEncrypted() bool {
+	return ws.MasterKey != nil
+}
+
+// IsLocked checks whether private keys of the wallets are unavailable.
+func (ws *Wallets) IsLocked() bool {
+	return ws.IsEncrypted() && ws.unlockedKey == nil
+}
+
+// Encrypt encrypts every private key with a new master key protected by
+// passphrase. The wallets stay unlocked.
+func (ws *Wallets) Encrypt(passphrase string) error {
+	if ws.IsEncrypted() {
+		return errWalletEncrypted
+	}
+
+	mk, key, err := newMasterKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	for _, wallet := range ws.Wallets {
+		err := wallet.encrypt(key)
+		if err != nil {
+			return err
+		}
+	}
+	if ws.IsHD() {
+		err := ws.HDChain.encrypt(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	ws.MasterKey = mk
+	ws.unlockedKey = key
+
+	return nil
+}
+
+// Unlock decrypts the private keys with passphrase.
+func (ws *Wallets) Unlock(passphrase string) error {
+	if !ws.IsEncrypted() {
+		return errWalletNotEncrypted
+	}
+
+	key, err := ws.MasterKey.open(passphrase)
+	if err != nil {
+		return err
+	}
+
+	return ws.unlock(key)
+}
+
+func (ws *Wallets) unlock(key []byte) error {
+	for _, wallet := range ws.Wallets {
+		err := wallet.decrypt(key)
+		if err != nil {
+			return err
+		}
+	}
+	if ws.IsHD() {
+		err := ws.HDChain.decrypt(key)
+		if err != nil {
+			return err
+		}
+	}
+	ws.unlockedKey = key
+
+	return nil
+}
+
+// Lock forgets the decrypted private keys.
+func (ws *Wallets) Lock() {
+	if !ws.IsEncrypted() {
+		return
+	}
+
+	for _, wallet := range ws.Wallets {
+		wallet.PrivateKey.D = nil
+	}
+	if ws.IsHD() {
+		ws.HDChain.Seed = nil
+	}
+	ws.unlockedKey = nil
+}
+
+// ChangePassphrase seals the master key with a new passphrase.
+func (ws *Wallets) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if !ws.IsEncrypted() {
+		return errWalletNotEncrypted
+	}
+
+	key, err := ws.MasterKey.open(oldPassphrase)
+	if err != nil {
+		return err
+	}
+
+	mk, err := sealMasterKey(key, newPassphrase)
+	if err != nil {
+		return err
+	}
+	ws.MasterKey = mk
+
+	return nil
+}
+
+// ServeUnlockSession keeps the wallets unlocked until timeout elapses or
+// walletlock is run, handing the master key to the commands run meanwhile.
+// The key only lives in the memory of this process: it is served over a
+// socket in a directory only readable by its owner and never written to
+// disk.
+func (ws *Wallets) ServeUnlockSession(nodeID string, timeout time.Duration) error {
+	if ws.IsLocked() {
+		return errWalletLocked
+	}
+
+	l, err := listenUnlockSession(nodeID)
+	if err != nil {
+		return err
+	}
+
+	return ws.serveUnlockSession(l, timeout)
+}
+
+// listenUnlockSession locks the wallets if a session is running and listens
+// for the commands of a new one.
+func listenUnlockSession(nodeID string) (net.Listener, error) {
+	err := RemoveUnlockSession(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionFile := unlockSessionFile(nodeID)
+	err = os.MkdirAll(filepath.Dir(sessionFile), 0700)
+	if err == nil {
+		err = os.Chmod(filepath.Dir(sessionFile), 0700)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return net.Listen("unix", sessionFile)
+}
+
+func (ws *Wallets) serveUnlockSession(l net.Listener, timeout time.Duration) error {
+	defer ws.Lock()
+
+	// Closing the listener ends the session.
+	timer := time.AfterFunc(timeout, func() { l.Close() })
+	defer timer.Stop()
+
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			l.Close()
+			return err
+		}
+
+		var request unlockRequest
+		conn.SetDeadline(time.Now().Add(unlockSessionTimeout))
+		err = gob.NewDecoder(conn).Decode(&request)
+		if err == nil && request.Lock {
+			// The socket is gone once the connection is closed, so a new
+			// session can take its place.
+			l.Close()
+		} else if err == nil {
+			gob.NewEncoder(conn).Encode(unlockSession{Key: ws.unlockedKey})
+		}
+		conn.Close()
+	}
+}
+
+// loadUnlockSession unlocks the wallets if walletpassphrase is running.
+func (ws *Wallets) loadUnlockSession(nodeID string) error {
+	if !ws.IsEncrypted() {
+		return nil
+	}
+
+	conn, err := dialUnlockSession(nodeID)
+	if err != nil {
+		// No session is running.
+		return nil
+	}
+	defer conn.Close()
+
+	err = gob.NewEncoder(conn).Encode(unlockRequest{})
+	if err != nil {
+		return err
+	}
+	var session unlockSession
+	err = gob.NewDecoder(conn).Decode(&session)
+	if err == io.EOF {
+		// The session was locked meanwhile.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return ws.unlock(session.Key)
+}
+
+// RemoveUnlockSession locks the wallets before the session expires.
+func RemoveUnlockSession(nodeID string) error {
+	conn, err := dialUnlockSession(nodeID)
+	if err != nil {
+		// Remove what a session that did not stop cleanly left behind.
+		err = os.Remove(unlockSessionFile(nodeID))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	defer conn.Close()
+
+	err = gob.NewEncoder(conn).Encode(unlockRequest{Lock: true})
+	if err != nil {
+		return err
+	}
+	// Wait for the session to close the connection.
+	_, err = io.Copy(ioutil.Discard, conn)
+	return err
+}
+
+func dialUnlockSession(nodeID string) (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", unlockSessionFile(nodeID), unlockSessionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(unlockSessionTimeout))
+
+	return conn, nil
+}
+
+// unlockSessionFile returns the socket walletpassphrase listens on.
+func unlockSessionFile(nodeID string) string {
+	return filepath.Join(walletFileName(nodeID)+".unlock", "socket")
+}
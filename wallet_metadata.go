@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// walletFileVersion is the version of the wallet file format written.
+// Version 1 added address metadata.
+const walletFileVersion = 1
+
+// AddressMetadata is what the wallet file records about an address besides
+// its key.
+type AddressMetadata struct {
+	Label string
+	Note  string
+	// Created is the Unix time the address was added, 0 for addresses added
+	// before metadata was recorded.
+	Created int64
+	// Change tells whether the address receives change rather than
+	// payments.
+	Change bool
+}
+
+// AddressInfo describes an address held or watched by the wallets.
+type AddressInfo struct {
+	Address   string
+	WatchOnly bool
+	AddressMetadata
+}
+
+// addMetadata records the metadata of an address added to the wallets,
+// unless it has some already.
+func (ws *Wallets) addMetadata(address string, change bool) {
+	if ws.Metadata == nil {
+		ws.Metadata = make(map[string]*AddressMetadata)
+	}
+	if _, ok := ws.Metadata[address]; ok {
+		return
+	}
+
+	ws.Metadata[address] = &AddressMetadata{Created: clock().Unix(), Change: change}
+}
+
+// migrate upgrades wallets loaded from a file of an older format version.
+func (ws *Wallets) migrate() error {
+	if ws.Version > walletFileVersion {
+		return fmt.Errorf("error: wallet file version %d is newer than the supported version %d", ws.Version, walletFileVersion)
+	}
+
+	// Version 0 has no metadata. When the addresses were added and which
+	// ones receive change is unknown.
+	if ws.Version < 1 {
+		if ws.Metadata == nil {
+			ws.Metadata = make(map[string]*AddressMetadata)
+		}
+		for address := range ws.Wallets {
+			ws.Metadata[address] = &AddressMetadata{}
+		}
+		for address := range ws.WatchOnly {
+			ws.Metadata[address] = &AddressMetadata{}
+		}
+	}
+
+	ws.Version = walletFileVersion
+	return nil
+}
+
+// SetLabel sets the label and the note of an address held or watched by the
+// wallets. The Bech32 address of a key held labels its Base58 address.
+func (ws *Wallets) SetLabel(address, label, note string) error {
+	if wallet := ws.FindAddress(address); wallet != nil {
+		address = string(wallet.GetAddress())
+	} else if !ws.IsWatchOnly(address) {
+		return fmt.Errorf("error: %s is not in the wallet file", address)
+	}
+
+	ws.addMetadata(address, false)
+	ws.Metadata[address].Label = label
+	ws.Metadata[address].Note = note
+	return nil
+}
+
+// ListAddresses returns the addresses held or watched by the wallets, sorted.
+// If label is not empty, only the addresses with that label are returned.
+func (ws *Wallets) ListAddresses(label string) []AddressInfo {
+	var infos []AddressInfo
+
+	add := func(address string, watchOnly bool) {
+		info := AddressInfo{Address: address, WatchOnly: watchOnly}
+		if metadata := ws.Metadata[address]; metadata != nil {
+			info.AddressMetadata = *metadata
+		}
+		if label == "" || info.Label == label {
+			infos = append(infos, info)
+		}
+	}
+	for address := range ws.Wallets {
+		add(address, false)
+	}
+	for address := range ws.WatchOnly {
+		add(address, true)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Address < infos[j].Address
+	})
+	return infos
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddressMetadata(t *testing.T) {
+	clock = func() time.Time { return time.Unix(1500000000, 0) }
+	defer func() { clock = time.Now }()
+
+	wallets := &Wallets{Wallets: map[string]*Wallet{}}
+	assert.Nil(t, wallets.SetHDSeed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"))
+	receive := wallets.CreateWallet()
+	change := wallets.CreateChangeWallet()
+	watched := string(NewWallet().GetAddress())
+	assert.Nil(t, wallets.ImportAddress(watched))
+
+	assert.Equal(t, AddressMetadata{Created: 1500000000}, *wallets.Metadata[receive])
+	assert.True(t, wallets.Metadata[change].Change)
+
+	assert.Nil(t, wallets.SetLabel(receive, "savings", "from the sale"))
+	assert.Nil(t, wallets.SetLabel(watched, "savings", ""))
+	assert.NotNil(t, wallets.SetLabel(string(NewWallet().GetAddress()), "savings", ""))
+
+	all := wallets.ListAddresses("")
+	assert.Len(t, all, 3)
+	assert.True(t, sort.SliceIsSorted(all, func(i, j int) bool { return all[i].Address < all[j].Address }))
+
+	expected := []AddressInfo{
+		{Address: receive, AddressMetadata: AddressMetadata{Label: "savings", Note: "from the sale", Created: 1500000000}},
+		{Address: watched, WatchOnly: true, AddressMetadata: AddressMetadata{Label: "savings", Created: 1500000000}},
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i].Address < expected[j].Address })
+	assert.Equal(t, expected, wallets.ListAddresses("savings"))
+}
+
+func TestWalletFileMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.dat")
+
+	// Version 0 files have no metadata.
+	old := Wallets{Wallets: map[string]*Wallet{}}
+	address := old.addWallet(NewWallet())
+	old.Metadata = nil
+	assert.Nil(t, ioutil.WriteFile(path, gobEncode(old), 0600))
+
+	wallets := &Wallets{Wallets: map[string]*Wallet{}}
+	assert.Nil(t, wallets.loadFile(path))
+	assert.Equal(t, walletFileVersion, wallets.Version)
+	assert.Equal(t, AddressMetadata{}, *wallets.Metadata[address])
+
+	newer := Wallets{Version: walletFileVersion + 1, Wallets: map[string]*Wallet{}}
+	assert.Nil(t, ioutil.WriteFile(path, gobEncode(newer), 0600))
+	assert.NotNil(t, wallets.loadFile(path), "Newer versions are not read.")
+}
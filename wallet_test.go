@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAddrNetwork(t *testing.T) {
+	defer func() { activeNet = &MainNetParams }()
+
+	wallet := NewWallet()
+	for _, params := range []*ChainParams{&MainNetParams, &TestNetParams, &RegTestParams} {
+		activeNet = params
+		address := string(wallet.GetAddress())
+		scriptAddress := ScriptHashAddress([]byte{OP_1})
+		bech32Address := string(wallet.GetBech32Address())
+
+		for _, other := range []*ChainParams{&MainNetParams, &TestNetParams, &RegTestParams} {
+			activeNet = other
+			assert.Equal(t, params == other, ValidateAddr(address), "%s address on %s.", params.Name, other.Name)
+			assert.Equal(t, params == other, ValidateAddr(scriptAddress), "%s script address on %s.", params.Name, other.Name)
+			assert.Equal(t, params == other, ValidateAddr(bech32Address), "%s Bech32 address on %s.", params.Name, other.Name)
+		}
+	}
+}
+
+func TestWalletsEncryption(t *testing.T) {
+	wallets := Wallets{Wallets: make(map[string]*Wallet)}
+	address := wallets.CreateWallet()
+	d := wallets.Wallets[address].PrivateKey.D
+
+	assert.Nil(t, wallets.Encrypt("secret"))
+	assert.False(t, wallets.IsLocked(), "Wallets stay unlocked after encryption.")
+
+	var stored Wallets
+	err := gob.NewDecoder(bytes.NewReader(gobEncode(wallets))).Decode(&stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, stored.IsLocked(), "Stored wallets are locked.")
+	assert.Nil(t, stored.Wallets[address].PrivateKey.D, "Private key is not stored in clear.")
+
+	assert.Equal(t, errIncorrectPassphrase, stored.Unlock("wrong"))
+	assert.Nil(t, stored.ChangePassphrase("secret", "new secret"))
+	assert.Equal(t, errIncorrectPassphrase, stored.Unlock("secret"))
+	assert.Nil(t, stored.Unlock("new secret"))
+	assert.Equal(t, d, stored.Wallets[address].PrivateKey.D, "Private key is restored.")
+
+	stored.Lock()
+	assert.True(t, stored.IsLocked())
+	assert.Nil(t, stored.Wallets[address].PrivateKey.D)
+}
+
+func TestUnlockSession(t *testing.T) {
+	nodeID := "unlock_test"
+	defer os.RemoveAll(filepath.Dir(unlockSessionFile(nodeID)))
+
+	wallets := Wallets{Wallets: make(map[string]*Wallet)}
+	address := wallets.CreateWallet()
+	assert.Nil(t, wallets.Encrypt("secret"))
+	content := gobEncode(wallets)
+
+	load := func() *Wallets {
+		var stored Wallets
+		err := gob.NewDecoder(bytes.NewReader(content)).Decode(&stored)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, stored.loadUnlockSession(nodeID))
+		return &stored
+	}
+	serve := func(timeout time.Duration) chan error {
+		l, err := listenUnlockSession(nodeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan error)
+		go func() { done <- wallets.serveUnlockSession(l, timeout) }()
+		return done
+	}
+
+	assert.True(t, load().IsLocked(), "No session is running.")
+
+	done := serve(time.Hour)
+	stored := load()
+	assert.False(t, stored.IsLocked())
+	assert.Equal(t, wallets.Wallets[address].PrivateKey.D, stored.Wallets[address].PrivateKey.D)
+
+	assert.Nil(t, RemoveUnlockSession(nodeID))
+	assert.Nil(t, <-done)
+	assert.True(t, load().IsLocked(), "walletlock ends the session.")
+	assert.True(t, wallets.IsLocked(), "The session forgets the key.")
+
+	assert.Nil(t, wallets.Unlock("secret"))
+	done = serve(10 * time.Millisecond)
+	assert.Nil(t, <-done)
+	assert.True(t, load().IsLocked(), "The session expires.")
+}
+
+func TestRejectLegacyWallets(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private.Curve = legacyP256{elliptic.P256().Params()}
+	legacy := struct {
+		Wallets map[string]*legacyWallet
+	}{map[string]*legacyWallet{"old address": {
+		PrivateKey: *private,
+		PublicKey:  append(private.X.Bytes(), private.Y.Bytes()...),
+	}}}
+
+	walletFile := filepath.Join(t.TempDir(), "wallet.dat")
+	err = ioutil.WriteFile(walletFile, gobEncode(legacy), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wallets Wallets
+	assert.Equal(t, errLegacyWallets, wallets.loadFile(walletFile), "Legacy keys are swept, not loaded.")
+}
+
+func TestHDWalletsAreDeterministic(t *testing.T) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	create := func() []string {
+		wallets := Wallets{Wallets: make(map[string]*Wallet)}
+		assert.Nil(t, wallets.SetHDSeed(mnemonic))
+		return []string{wallets.CreateWallet(), wallets.CreateWallet(), wallets.CreateChangeWallet()}
+	}
+
+	first := create()
+	assert.Equal(t, first, create(), "Addresses derive from the seed.")
+	assert.NotEqual(t, first[0], first[1])
+	assert.NotEqual(t, first[0], first[2])
+
+	exhausted := Wallets{Wallets: make(map[string]*Wallet)}
+	assert.Nil(t, exhausted.SetHDSeed(mnemonic))
+	exhausted.HDChain.NextIndex[externalChain] = hardenedKeyStart
+	assert.Panics(t, func() { exhausted.CreateWallet() }, "Hardened indexes are not handed out.")
+
+	wallets := Wallets{Wallets: make(map[string]*Wallet)}
+	assert.NotNil(t, wallets.SetHDSeed("not a valid mnemonic"))
+}
@@ -2,34 +2,64 @@ package main
 
 import (
 	"bytes"
-	"crypto/elliptic"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 )
 
-const walletFile = "wallet_%s.dat"
+// errLegacyWallets is returned for wallet files written before keys could be
+// encrypted. Their P-256 keys cannot sign for the secp256k1 scripts of the
+// chain, and their addresses hash uncompressed public keys.
+var errLegacyWallets = errors.New("error: the wallet file holds legacy P-256 keys that cannot spend, sweep their coins with the release that wrote it and create a new wallet")
 
 // Wallets stores a collection of wallet.
 type Wallets struct {
+	// Version is the format version of the wallet file, see migrate.
+	Version int
 	Wallets map[string]*Wallet
+	// MasterKey seals the private keys of an encrypted wallet file.
+	MasterKey *masterKey
+	// HDChain derives new keys from a seed. Wallet files created before HD
+	// support have no chain and get random keys.
+	HDChain *hdChain
+	// WatchOnly maps the addresses watched without their private key to
+	// their public key, nil if it was not imported.
+	WatchOnly map[string][]byte
+	// Metadata maps the addresses held or watched to what is recorded about
+	// them.
+	Metadata map[string]*AddressMetadata
+
+	// unlockedKey is the decrypted master key while the wallets are unlocked.
+	unlockedKey []byte
 }
 
 // NewWallets creates Wallets and fills it from a file if it exists.
+// An encrypted wallet file is unlocked when a walletpassphrase session is
+// still running.
 func NewWallets(nodeID string) (*Wallets, error) {
 	wallets := Wallets{}
 	wallets.Wallets = make(map[string]*Wallet)
 
 	err := wallets.LoadFromFile(nodeID)
+	if err != nil {
+		return &wallets, err
+	}
+
+	err = wallets.loadUnlockSession(nodeID)
 
 	return &wallets, err
 }
 
 // LoadFromFile loads wallets from the file.
 func (ws *Wallets) LoadFromFile(nodeID string) error {
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+	return ws.loadFile(walletFileName(nodeID))
+}
+
+// loadFile loads wallets from walletFile.
+func (ws *Wallets) loadFile(walletFile string) error {
 	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
 		return err
 	}
@@ -40,16 +70,24 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 	}
 
 	var wallets Wallets
-	gob.Register(elliptic.P256())
 	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
 	err = decoder.Decode(&wallets)
 	if err != nil {
+		// Files written before keys could be encrypted hold legacy wallets.
+		if isLegacyWalletFile(fileContent) {
+			return errLegacyWallets
+		}
 		log.Panic(err)
 	}
 
 	ws.Wallets = wallets.Wallets
+	ws.MasterKey = wallets.MasterKey
+	ws.HDChain = wallets.HDChain
+	ws.WatchOnly = wallets.WatchOnly
+	ws.Version = wallets.Version
+	ws.Metadata = wallets.Metadata
 
-	return nil
+	return ws.migrate()
 }
 
 // GetWallet returns a Wallet by its address.
@@ -57,12 +95,49 @@ func (ws Wallets) GetWallet(address string) Wallet {
 	return *ws.Wallets[address]
 }
 
-// CreateWallet creates a Wallet and adds it to Wallets.
+// FindWallet returns the Wallet whose public key hashes to pubKeyHash, or nil
+// if the key is not in the wallet file.
+func (ws Wallets) FindWallet(pubKeyHash []byte) *Wallet {
+	return ws.Wallets[encodeAddress(activeNet.AddressVersion, pubKeyHash)]
+}
+
+// FindAddress returns the Wallet holding the key address pays to, given by
+// its Base58 or its Bech32 address, or nil if the key is not in the wallet
+// file.
+func (ws Wallets) FindAddress(address string) *Wallet {
+	script, err := PayToAddrScript(address)
+	if err != nil {
+		return nil
+	}
+	pubKeyHash := extractPubKeyHash(scriptCode(script))
+	if pubKeyHash == nil {
+		return nil
+	}
+
+	return ws.FindWallet(pubKeyHash)
+}
+
+// CreateWallet creates a Wallet to receive coins and adds it to Wallets.
+// HD wallets derive it from the next index of the external chain.
+// Encrypted wallets have to be unlocked first.
 func (ws *Wallets) CreateWallet() string {
-	wallet := NewWallet()
-	address := fmt.Sprintf("%s", ws.GetAddrs())
+	return ws.createWallet(externalChain)
+}
+
+func (ws *Wallets) createWallet(chain uint32) string {
+	if ws.IsLocked() {
+		log.Panic(errWalletLocked)
+	}
+
+	var wallet *Wallet
+	if ws.IsHD() {
+		wallet = ws.HDChain.nextWallet(chain)
+	} else {
+		wallet = NewWallet()
+	}
 
-	ws.Wallets[address] = wallet
+	address := ws.addWallet(wallet)
+	ws.Metadata[address].Change = chain == internalChain
 	return address
 }
 
@@ -78,19 +153,24 @@ func (ws *Wallets) GetAddrs() []string {
 }
 
 // SaveToFile saves wallets to a file.
+// The file is replaced atomically and is only readable by its owner.
 func (ws Wallets) SaveToFile(nodeID string) {
 	var content bytes.Buffer
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+	walletFile := walletFileName(nodeID)
+	ws.Version = walletFileVersion
 
-	gob.Register(elliptic.P256())
 	encoder := gob.NewEncoder(&content)
 	err := encoder.Encode(ws)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0644)
+	err = writeFileAtomic(walletFile, content.Bytes(), 0600)
 	if err != nil {
 		log.Panic(err)
 	}
 }
+
+func walletFileName(nodeID string) string {
+	return fmt.Sprintf(activeNet.WalletFile, nodeID)
+}
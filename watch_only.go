@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errWatchOnly = errors.New("error: the wallet file only watches this address, sign offline with -unsigned")
+
+// ImportAddress makes the wallets watch address without holding its key, so
+// that transactions spending its coins can be built here and signed on the
+// machine holding the key.
+func (ws *Wallets) ImportAddress(address string) error {
+	if !ValidateAddr(address) {
+		return errInvalidAddress
+	}
+	if ws.FindAddress(address) != nil {
+		return fmt.Errorf("error: the wallet file holds the key of %s", address)
+	}
+
+	if ws.WatchOnly == nil {
+		ws.WatchOnly = make(map[string][]byte)
+	}
+	if _, ok := ws.WatchOnly[address]; !ok {
+		ws.WatchOnly[address] = nil
+	}
+	ws.addMetadata(address, false)
+	return nil
+}
+
+// ImportPubKey makes the wallets watch the pay-to-pubkey-hash address of
+// pubKey. Unlike an address, the public key can also be used in multisig
+// scripts. It returns the address.
+func (ws *Wallets) ImportPubKey(pubKey []byte) (string, error) {
+	if _, err := activeNet.Curve.ParsePubKey(pubKey); err != nil {
+		return "", err
+	}
+
+	address := encodeAddress(activeNet.AddressVersion, HashPubKey(pubKey))
+	if err := ws.ImportAddress(address); err != nil {
+		return "", err
+	}
+	ws.WatchOnly[address] = pubKey
+
+	return address, nil
+}
+
+// IsWatchOnly checks whether the wallets watch address without its key.
+func (ws *Wallets) IsWatchOnly(address string) bool {
+	_, ok := ws.WatchOnly[address]
+	return ok
+}
+
+// GetWatchOnlyAddrs returns the addresses watched without their key.
+func (ws *Wallets) GetWatchOnlyAddrs() []string {
+	var addresses []string
+
+	for address := range ws.WatchOnly {
+		addresses = append(addresses, address)
+	}
+
+	return addresses
+}
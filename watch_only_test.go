@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportWatchOnly(t *testing.T) {
+	wallets := &Wallets{Wallets: map[string]*Wallet{}}
+	held := wallets.CreateWallet()
+	watched := NewWallet()
+
+	assert.NotNil(t, wallets.ImportAddress(held), "The key of the address is held.")
+	assert.NotNil(t, wallets.ImportAddress("invalid"))
+	_, err := wallets.ImportPubKey([]byte{0x02, 0x01})
+	assert.NotNil(t, err)
+
+	address, err := wallets.ImportPubKey(watched.PublicKey)
+	assert.Nil(t, err)
+	assert.Equal(t, string(watched.GetAddress()), address)
+	assert.True(t, wallets.IsWatchOnly(address))
+	assert.False(t, wallets.IsWatchOnly(held))
+	assert.Equal(t, []string{address}, wallets.GetWatchOnlyAddrs())
+
+	// Importing the address again keeps the public key.
+	assert.Nil(t, wallets.ImportAddress(address))
+	assert.Equal(t, watched.PublicKey, wallets.WatchOnly[address])
+}
+
+func TestUnsignedTransaction(t *testing.T) {
+	UTXOSet := testUTXOSet(t)
+
+	// The online wallet file watches from, the offline one holds its key.
+	offline := &Wallets{Wallets: map[string]*Wallet{}}
+	from := offline.CreateWallet()
+	online := &Wallets{Wallets: map[string]*Wallet{}}
+	assert.Nil(t, online.ImportAddress(from))
+	to := string(NewWallet().GetAddress())
+
+	coinbase := NewCoinbaseTX(from, "Block 1")
+	UTXOSet.Update(&Block{Hash: []byte{0xb1}, Height: 1, Transactions: []*Transaction{coinbase}})
+
+	_, err := NewUnsignedTransaction("invalid", []Payment{{to, 1}}, 0, &CoinControl{}, &UTXOSet)
+	assert.NotNil(t, err)
+
+	ptx, err := NewUnsignedTransaction(from, []Payment{{to, 4}}, 0, &CoinControl{Selector: LargestFirst{}}, &UTXOSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 4, ptx.Tx.Vout[0].Value)
+	assert.Equal(t, activeNet.Subsidy-4, ptx.Tx.Vout[1].Value, "Change goes back to from.")
+	assert.True(t, ptx.Tx.Vout[1].IsLockedWithKey(HashPubKey(offline.GetWallet(from).PublicKey)))
+
+	signed, err := ptx.Sign(online, SigHashAll)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, signed, "The online wallet file holds no key.")
+
+	// The offline machine signs the serialized transaction alone.
+	received, err := DeserializePartialTransaction(ptx.Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err = received.Sign(offline, SigHashAll)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, signed)
+	tx, err := received.Finalize()
+	assert.Nil(t, err)
+	assert.True(t, tx.Verify(map[string]Transaction{hex.EncodeToString(coinbase.ID): *coinbase}))
+
+	// Outputs paying to the Bech32 address of the key fund it too.
+	witnessCoinbase := NewCoinbaseTX(string(offline.GetWallet(from).GetBech32Address()), "Block 2")
+	UTXOSet.Update(&Block{Hash: []byte{0xb2}, Height: 2, Transactions: []*Transaction{witnessCoinbase}})
+	ptx, err = NewUnsignedTransaction(from, []Payment{{to, activeNet.Subsidy + 4}}, 0, &CoinControl{}, &UTXOSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, ptx.Tx.Vin, 2)
+	signed, err = ptx.Sign(offline, SigHashAll)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, signed)
+	tx, err = ptx.Finalize()
+	assert.Nil(t, err)
+	assert.True(t, tx.Verify(map[string]Transaction{
+		hex.EncodeToString(coinbase.ID):        *coinbase,
+		hex.EncodeToString(witnessCoinbase.ID): *witnessCoinbase,
+	}))
+}
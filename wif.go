@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// compressedKeyFlag follows the key in Wallet Import Format when its public
+// key is compressed, as are all public keys of the wallets.
+const compressedKeyFlag = 0x01
+
+var errInvalidWIF = errors.New("private key is not valid Wallet Import Format")
+
+// EncodeWIF returns the private key of wallet in Wallet Import Format: the
+// Base58Check encoding of the key prefixed with the private key version of
+// the active network and followed by the compressed key flag.
+func EncodeWIF(wallet *Wallet) (string, error) {
+	if wallet.PrivateKey.D == nil {
+		return "", errWalletLocked
+	}
+	if _, err := signingCurve(&wallet.PrivateKey); err != nil {
+		return "", err
+	}
+
+	payload := make([]byte, privKeyLen+1)
+	wallet.PrivateKey.D.FillBytes(payload[:privKeyLen])
+	payload[privKeyLen] = compressedKeyFlag
+
+	return Base58CheckEncode(activeNet.PrivateKeyVersion, payload), nil
+}
+
+// DecodeWIF returns the wallet of a private key in Wallet Import Format of
+// the active network.
+func DecodeWIF(wif string) (*Wallet, error) {
+	version, payload, err := Base58CheckDecode(wif)
+	if err != nil || len(payload) != privKeyLen+1 {
+		return nil, errInvalidWIF
+	}
+	if version != activeNet.PrivateKeyVersion {
+		return nil, fmt.Errorf("private key is not a %s key", activeNet.Name)
+	}
+	if payload[privKeyLen] != compressedKeyFlag {
+		return nil, errors.New("private key is not for a compressed public key")
+	}
+
+	d := payload[:privKeyLen]
+	if k := new(big.Int).SetBytes(d); k.Sign() == 0 || k.Cmp(activeNet.Curve.Elliptic().Params().N) >= 0 {
+		return nil, errInvalidWIF
+	}
+
+	private := activeNet.Curve.PrivateKeyFromBytes(d)
+	return &Wallet{PrivateKey: private, PublicKey: encodePubKey(&private.PublicKey)}, nil
+}
+
+// DumpPrivKey returns the private key of address in Wallet Import Format.
+// Encrypted wallets have to be unlocked first.
+func (ws *Wallets) DumpPrivKey(address string) (string, error) {
+	wallet := ws.FindAddress(address)
+	if wallet == nil {
+		return "", fmt.Errorf("error: the wallet file does not hold the key of %s", address)
+	}
+	if ws.IsLocked() {
+		return "", errWalletLocked
+	}
+
+	return EncodeWIF(wallet)
+}
+
+// ImportPrivKey adds the private key in Wallet Import Format to the wallets
+// like ImportKey.
+func (ws *Wallets) ImportPrivKey(wif string) (string, error) {
+	wallet, err := DecodeWIF(wif)
+	if err != nil {
+		return "", err
+	}
+
+	return ws.ImportKey(wallet)
+}
+
+// ImportKey adds the key of wallet to the wallets, which stop only watching
+// its address. Encrypted wallets have to be unlocked first. It returns the
+// address of the key.
+func (ws *Wallets) ImportKey(wallet *Wallet) (string, error) {
+	if ws.IsLocked() {
+		return "", errWalletLocked
+	}
+
+	address := string(wallet.GetAddress())
+	if _, ok := ws.Wallets[address]; !ok {
+		ws.addWallet(wallet)
+	}
+	delete(ws.WatchOnly, address)
+
+	return address, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWIF(t *testing.T) {
+	defer func() { activeNet = &MainNetParams }()
+
+	d, _ := hex.DecodeString("0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d")
+	private := Secp256k1.PrivateKeyFromBytes(d)
+	wallet := &Wallet{PrivateKey: private, PublicKey: encodePubKey(&private.PublicKey)}
+
+	wif, err := EncodeWIF(wallet)
+	assert.Nil(t, err)
+	assert.Equal(t, "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617", wif)
+
+	decoded, err := DecodeWIF(wif)
+	assert.Nil(t, err)
+	assert.Equal(t, wallet.GetAddress(), decoded.GetAddress())
+
+	for _, invalid := range []string{"", "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98618", "5HueCWoy3Y3xiCvX7Zb1MTGBCWkzJVrXMAZ8Xhy8oVAGrbcmR6z"} {
+		_, err := DecodeWIF(invalid)
+		assert.NotNil(t, err, "WIF %q.", invalid)
+	}
+
+	activeNet = &RegTestParams
+	_, err = DecodeWIF(wif)
+	assert.NotNil(t, err, "Mainnet keys are not regtest keys.")
+	wif, err = EncodeWIF(wallet)
+	assert.Nil(t, err)
+	assert.Equal(t, byte('c'), wif[0])
+}
+
+func TestDumpImportPrivKey(t *testing.T) {
+	wallets := &Wallets{Wallets: map[string]*Wallet{}}
+	address := wallets.CreateWallet()
+	assert.Nil(t, wallets.Encrypt("secret"))
+	wif, err := wallets.DumpPrivKey(address)
+	assert.Nil(t, err)
+
+	wallets.Lock()
+	_, err = wallets.DumpPrivKey(address)
+	assert.Equal(t, errWalletLocked, err)
+	_, err = wallets.DumpPrivKey(string(NewWallet().GetAddress()))
+	assert.NotNil(t, err)
+
+	other := &Wallets{Wallets: map[string]*Wallet{}}
+	assert.Nil(t, other.ImportAddress(address))
+	imported, err := other.ImportPrivKey(wif)
+	assert.Nil(t, err)
+	assert.Equal(t, address, imported)
+	assert.False(t, other.IsWatchOnly(address), "The address is no longer only watched.")
+	assert.Equal(t, wallets.Wallets[address].PublicKey, other.Wallets[address].PublicKey)
+}
+
+func TestBackupWallet(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	wallets := &Wallets{Wallets: map[string]*Wallet{}}
+	held := wallets.CreateWallet()
+	watched := string(NewWallet().GetAddress())
+	assert.Nil(t, wallets.ImportAddress(watched))
+	assert.Nil(t, wallets.Encrypt("secret"))
+	wallets.SaveToFile("3000")
+
+	path := filepath.Join(t.TempDir(), "backup.dat")
+	assert.Nil(t, BackupWallet("3000", path))
+	backup, err := LoadBackup(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := &Wallets{Wallets: map[string]*Wallet{}}
+	existing := other.CreateWallet()
+	_, err = other.ImportWallets(backup)
+	assert.Equal(t, errWalletLocked, err, "The backup is encrypted.")
+
+	assert.Nil(t, backup.Unlock("secret"))
+	imported, err := other.ImportWallets(backup)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{held, watched}, imported)
+	assert.ElementsMatch(t, []string{held, existing}, other.GetAddrs())
+	assert.True(t, other.IsWatchOnly(watched))
+
+	// Keys are stored in clear in the unencrypted wallets.
+	wif, err := other.DumpPrivKey(held)
+	assert.Nil(t, err)
+	expected, err := backup.DumpPrivKey(held)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, wif)
+	assert.Nil(t, other.Wallets[held].EncryptedKey)
+
+	imported, err = other.ImportWallets(backup)
+	assert.Nil(t, err)
+	assert.Empty(t, imported, "Nothing is imported twice.")
+}